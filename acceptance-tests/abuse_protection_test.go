@@ -0,0 +1,64 @@
+package acceptance_tests
+
+import (
+	"fmt"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Abuse Protection", func() {
+	opsfileAbuseProtection := `---
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/abuse_protection?
+  value:
+    window_size: 60s
+    table_size: 100k
+    block: true
+    action: deny
+    paths:
+    - name: login
+      prefix: /login
+      requests: ((login_requests))
+`
+
+	It("Denies sources exceeding the request rate on a path prefix", func() {
+		loginRequests := 5
+		haproxyBackendPort := 12000
+		haproxyInfo, _ := deployHAProxy(baseManifestVars{
+			haproxyBackendPort:    haproxyBackendPort,
+			haproxyBackendServers: []string{"127.0.0.1"},
+			deploymentName:        deploymentNameForTestNode(),
+		}, []string{opsfileAbuseProtection}, map[string]interface{}{
+			"login_requests": loginRequests,
+		}, true)
+
+		closeLocalServer, localPort := startDefaultTestServer()
+		defer closeLocalServer()
+
+		closeTunnel := setupTunnelFromHaproxyToTestServer(haproxyInfo, haproxyBackendPort, localPort)
+		defer closeTunnel()
+
+		client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+		loginURL := fmt.Sprintf("http://%s/login", haproxyInfo.PublicIP)
+
+		By("Sending requests to the login path up to the limit, expecting none to be denied")
+		for i := 0; i < loginRequests; i++ {
+			expectTestServer200(client.Get(loginURL))
+		}
+
+		By("Sending a request exceeding the limit, expecting it to be denied")
+		resp, err := client.Get(loginURL)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusTooManyRequests))
+
+		By("Sending a request to another path, expecting it not to be denied")
+		expectTestServer200(client.Get(fmt.Sprintf("http://%s/foo", haproxyInfo.PublicIP)))
+
+		By("Disabling blocking at runtime via socket, expecting requests to the login path to pass again")
+		runHAProxySocketCommand(haproxyInfo, "experimental-mode on; set var proc.abuse_protection_block bool(false)")
+		Expect(runHAProxySocketCommand(haproxyInfo, "get var proc.abuse_protection_block")).To(ContainSubstring("value=<0>"))
+		expectTestServer200(client.Get(loginURL))
+	})
+})
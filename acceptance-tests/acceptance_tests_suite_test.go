@@ -68,7 +68,7 @@ type TestServerOption func(*httptest.Server)
 
 func withIP(ip string) TestServerOption {
 	return func(server *httptest.Server) {
-		l, err := net.Listen("tcp", fmt.Sprintf("%s:0", ip))
+		l, err := net.Listen("tcp", net.JoinHostPort(ip, "0"))
 		Expect(err).ToNot(HaveOccurred())
 		server.Listener = l
 	}
@@ -178,6 +178,26 @@ func setupTunnelFromLocalMachineToHAProxy(haproxyInfo haproxyInfo, localPort, ha
 	return cancelFunc
 }
 
+// Sets up SSH tunnel from local machine to a UNIX socket on the HAProxy VM
+func setupTunnelFromLocalMachineToHAProxySocket(haproxyInfo haproxyInfo, localPort int, socketPath string) func() {
+	By(fmt.Sprintf("Creating a SSH tunnel from localmachine (port %d) to HAProxy (socket %s)", localPort, socketPath))
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	err := startSSHPortToUnixSocketForwarder(haproxyInfo.SSHUser, haproxyInfo.PublicIP, haproxyInfo.SSHPrivateKey, localPort, socketPath, ctx)
+	Expect(err).NotTo(HaveOccurred())
+
+	return cancelFunc
+}
+
+// Sets up SSH tunnel from local machine IP to HAProxy IP, e.g. ::1 to reach HAProxy via IPv6
+func setupTunnelFromLocalMachineIPToHAProxyIP(haproxyInfo haproxyInfo, localIP string, localPort int, haproxyIP string, haproxyPort int) func() {
+	By(fmt.Sprintf("Creating a SSH tunnel from localmachine (ip %s port %d) to HAProxy (ip %s port %d)", localIP, localPort, haproxyIP, haproxyPort))
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	err := startSSHPortAndIPForwarder(haproxyInfo.SSHUser, haproxyInfo.PublicIP, haproxyInfo.SSHPrivateKey, localIP, localPort, haproxyIP, haproxyPort, ctx)
+	Expect(err).NotTo(HaveOccurred())
+
+	return cancelFunc
+}
+
 func expectTestServer200(resp *http.Response, err error) {
 	Expect(err).NotTo(HaveOccurred())
 	Expect(resp.StatusCode).To(Equal(http.StatusOK))
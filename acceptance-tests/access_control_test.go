@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 	"syscall"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -141,6 +142,61 @@ var _ = Describe("Access Control", func() {
 		expectTestServer200(http.Get("http://127.0.0.1:11000"))
 	})
 
+	It("Rejects IPs added to the TCP-layer blocklist at runtime", func() {
+		haproxyBackendPort := 12000
+
+		haproxyInfo, _ := deployHAProxy(baseManifestVars{
+			haproxyBackendPort:    haproxyBackendPort,
+			haproxyBackendServers: []string{"127.0.0.1"},
+			deploymentName:        deploymentNameForTestNode(),
+		}, []string{}, map[string]interface{}{}, true)
+
+		closeLocalServer, localPort := startDefaultTestServer()
+		defer closeLocalServer()
+
+		closeBackendTunnel := setupTunnelFromHaproxyToTestServer(haproxyInfo, haproxyBackendPort, localPort)
+		defer closeBackendTunnel()
+
+		expectBlocked := func() {
+			resp, err := http.Get(fmt.Sprintf("http://%s", haproxyInfo.PublicIP))
+			if err == nil {
+				_, err = io.ReadAll(resp.Body)
+				_ = resp.Body.Close()
+			}
+			Expect(err).To(HaveOccurred())
+			Expect(errors.Is(err, syscall.ECONNRESET)).To(BeTrue())
+		}
+
+		By("Allowing TCP connections before the test runner is blocked")
+		expectTestServer200(http.Get(fmt.Sprintf("http://%s", haproxyInfo.PublicIP)))
+
+		By("Blocking the test runner CIDR with haproxy-ctl")
+		// traffic from test runner appears to come from this CIDR block
+		_, _, err := runOnRemote(haproxyInfo.SSHUser, haproxyInfo.PublicIP, haproxyInfo.SSHPrivateKey, "sudo haproxy-ctl acl add blocklist_tcp 10.0.0.0/8")
+		Expect(err).NotTo(HaveOccurred())
+
+		By("Denying TCP connections from the blocked CIDR without a redeploy")
+		expectBlocked()
+
+		By("Persisting the blocked CIDR in the backing file")
+		stdout, _, err := runOnRemote(haproxyInfo.SSHUser, haproxyInfo.PublicIP, haproxyInfo.SSHPrivateKey, "sudo cat /var/vcap/jobs/haproxy/config/blocklist_cidrs_tcp.txt")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stdout).To(ContainSubstring("10.0.0.0/8"))
+
+		By("Still denying TCP connections from the blocked CIDR after a reload")
+		_, _, err = runOnRemote(haproxyInfo.SSHUser, haproxyInfo.PublicIP, haproxyInfo.SSHPrivateKey, "sudo /var/vcap/jobs/haproxy/bin/reload")
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(func() string {
+			return runHAProxySocketCommand(haproxyInfo, "show acl /var/vcap/jobs/haproxy/config/blocklist_cidrs_tcp.txt")
+		}, 30*time.Second, time.Second).Should(ContainSubstring("10.0.0.0/8"))
+		expectBlocked()
+
+		By("Allowing TCP connections again after removing the CIDR with haproxy-ctl")
+		_, _, err = runOnRemote(haproxyInfo.SSHUser, haproxyInfo.PublicIP, haproxyInfo.SSHPrivateKey, "sudo haproxy-ctl acl del blocklist_tcp 10.0.0.0/8")
+		Expect(err).NotTo(HaveOccurred())
+		expectTestServer200(http.Get(fmt.Sprintf("http://%s", haproxyInfo.PublicIP)))
+	})
+
 	It("Rejects IPs in TCP-layer blocklisted CIDRs when proxy protocol is enabled", func() {
 		haproxyBackendPort := 12000
 
@@ -178,4 +234,37 @@ var _ = Describe("Access Control", func() {
 		err = performProxyProtocolRequestWithSourceIP(haproxyInfo.PublicIP, 80, "/", "192.168.1.1")
 		Expect(err).NotTo(HaveOccurred())
 	})
+
+	It("Rejects IPv6 clients in TCP-layer blocklisted CIDRs when proxy protocol is enabled", func() {
+		haproxyBackendPort := 12000
+
+		opsfileTCPBlocklistWithProxyProtocol := opsfileTCPBlocklist + `
+# Enable Proxy Protocol
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/accept_proxy?
+  value: true
+`
+		haproxyInfo, _ := deployHAProxy(baseManifestVars{
+			haproxyBackendPort:    haproxyBackendPort,
+			haproxyBackendServers: []string{"127.0.0.1"},
+			deploymentName:        deploymentNameForTestNode(),
+		}, []string{opsfileTCPBlocklistWithProxyProtocol}, map[string]interface{}{
+			"cidr_blocklist_tcp": []string{"2001:db8::/32"},
+		}, true)
+
+		closeLocalServer, localPort := startDefaultTestServer()
+		defer closeLocalServer()
+
+		closeBackendTunnel := setupTunnelFromHaproxyToTestServer(haproxyInfo, haproxyBackendPort, localPort)
+		defer closeBackendTunnel()
+
+		By("Denying TCP connections from blocklisted IPv6 CIDRs via Proxy Protocol header (source IP 2001:db8::1)")
+		err := performProxyProtocolRequestWithSourceIP(haproxyInfo.PublicIP, 80, "/", "2001:db8::1")
+		Expect(err).To(HaveOccurred())
+		Expect(errors.Is(err, syscall.ECONNRESET)).To(BeTrue())
+
+		By("Allowing TCP connections from non-blocklisted IPv6 CIDRs via Proxy Protocol header (source IP 2001:db9::1)")
+		err = performProxyProtocolRequestWithSourceIP(haproxyInfo.PublicIP, 80, "/", "2001:db9::1")
+		Expect(err).NotTo(HaveOccurred())
+	})
 })
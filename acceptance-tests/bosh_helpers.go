@@ -332,3 +332,14 @@ func runHAProxySocketCommand(haproxyInfo haproxyInfo, command string) string {
 	return strings.TrimSpace(stdout)
 }
 
+// haproxyServerStatus returns the status column of `show stat` for a server, e.g. "UP", "DOWN" or "DOWN 1/2".
+func haproxyServerStatus(haproxyInfo haproxyInfo, backend string, server string) string {
+	for _, line := range strings.Split(runHAProxySocketCommand(haproxyInfo, "show stat"), "\n") {
+		fields := strings.Split(line, ",")
+		if len(fields) > 17 && fields[0] == backend && fields[1] == server {
+			return fields[17]
+		}
+	}
+	return ""
+}
+
@@ -0,0 +1,92 @@
+package acceptance_tests
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Response Caching", func() {
+	opsfileCache := `---
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/routed_backend_servers?
+  value:
+    /static:
+      servers: [127.0.0.1]
+      port: ((cache_backend_port))
+      cache:
+        max_age: ((cache_max_age))
+`
+
+	It("Serves cacheable responses from the cache until they expire", func() {
+		cacheBackendPort := 12001
+		cacheMaxAge := 10
+		haproxyInfo, _ := deployHAProxy(baseManifestVars{
+			haproxyBackendPort:    12000,
+			haproxyBackendServers: []string{"127.0.0.1"},
+			deploymentName:        deploymentNameForTestNode(),
+		}, []string{opsfileCache}, map[string]interface{}{
+			"cache_backend_port": cacheBackendPort,
+			"cache_max_age":      cacheMaxAge,
+		}, true)
+
+		var backendHits atomic.Int32
+		closeLocalServer, localPort, err := startLocalHTTPServer(nil, func(w http.ResponseWriter, r *http.Request) {
+			backendHits.Add(1)
+			if r.URL.Path == "/static/no-store" {
+				w.Header().Set("Cache-Control", "no-store")
+			} else {
+				// Longer than the cache max_age, which takes precedence
+				w.Header().Set("Cache-Control", "public, max-age=3600")
+			}
+			_, _ = w.Write([]byte("static content"))
+		})
+		Expect(err).NotTo(HaveOccurred())
+		defer closeLocalServer()
+
+		closeTunnel := setupTunnelFromHaproxyToTestServer(haproxyInfo, cacheBackendPort, localPort)
+		defer closeTunnel()
+
+		client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+		getXCache := func(path string) (string, error) {
+			resp, err := client.Get(fmt.Sprintf("http://%s%s", haproxyInfo.PublicIP, path))
+			if err != nil {
+				return "", err
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return "", fmt.Errorf("unexpected status code %d", resp.StatusCode)
+			}
+			return resp.Header.Get("X-Cache"), nil
+		}
+
+		By("Sending a first request, expecting a cache miss")
+		Eventually(func() (string, error) {
+			return getXCache("/static/app.js")
+		}, 30*time.Second, time.Second).Should(Equal("MISS"))
+		Expect(backendHits.Load()).To(Equal(int32(1)))
+
+		By("Sending further requests, expecting them to be served from the cache")
+		for i := 0; i < 5; i++ {
+			Expect(getXCache("/static/app.js")).To(Equal("HIT"))
+		}
+		Expect(backendHits.Load()).To(Equal(int32(1)))
+
+		By("Waiting for the cached response to expire, expecting the backend to be hit again")
+		time.Sleep(time.Duration(cacheMaxAge+1) * time.Second)
+		Expect(getXCache("/static/app.js")).To(Equal("MISS"))
+		Expect(backendHits.Load()).To(Equal(int32(2)))
+		Expect(getXCache("/static/app.js")).To(Equal("HIT"))
+		Expect(backendHits.Load()).To(Equal(int32(2)))
+
+		By("Sending requests for a response with Cache-Control no-store, expecting it not to be cached")
+		for i := 0; i < 3; i++ {
+			Expect(getXCache("/static/no-store")).To(Equal("MISS"))
+		}
+		Expect(backendHits.Load()).To(Equal(int32(5)))
+	})
+})
@@ -0,0 +1,117 @@
+package acceptance_tests
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Per-route Compression", func() {
+	opsfileCompression := `---
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/compress_types?
+  value: text/plain
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/routed_backend_servers?
+  value:
+    /compressed:
+      servers: [127.0.0.1]
+      port: ((compression_backend_port))
+      compression:
+        algorithms: [gzip, deflate]
+        min_size: 100
+        exclude_paths: [/compressed/login]
+    /uncompressed:
+      servers: [127.0.0.1]
+      port: ((compression_backend_port))
+      compression:
+        enabled: false
+`
+
+	It("Compresses responses according to the route and Accept-Encoding", func() {
+		compressionBackendPort := 12001
+		haproxyInfo, _ := deployHAProxy(baseManifestVars{
+			haproxyBackendPort:    12000,
+			haproxyBackendServers: []string{"127.0.0.1"},
+			deploymentName:        deploymentNameForTestNode(),
+		}, []string{opsfileCompression}, map[string]interface{}{
+			"compression_backend_port": compressionBackendPort,
+		}, true)
+
+		var backendAcceptEncoding sync.Map
+		closeLocalServer, localPort, err := startLocalHTTPServer(nil, func(w http.ResponseWriter, r *http.Request) {
+			backendAcceptEncoding.Store(r.URL.Path, r.Header.Get("Accept-Encoding"))
+			w.Header().Set("Content-Type", "text/plain")
+			switch {
+			case strings.HasSuffix(r.URL.Path, "/small"):
+				_, _ = w.Write([]byte("small"))
+			case strings.HasSuffix(r.URL.Path, "/brotli"):
+				// Not actually brotli, HAProxy must pass it through regardless
+				w.Header().Set("Content-Encoding", "br")
+				_, _ = w.Write([]byte(strings.Repeat("b", 1024)))
+			default:
+				_, _ = w.Write([]byte(strings.Repeat("compressible ", 200)))
+			}
+		})
+		Expect(err).NotTo(HaveOccurred())
+		defer closeLocalServer()
+
+		closeTunnel := setupTunnelFromHaproxyToTestServer(haproxyInfo, compressionBackendPort, localPort)
+		defer closeTunnel()
+
+		// Do not let the client negotiate compression, so that Content-Encoding is seen as sent by HAProxy
+		client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true, DisableCompression: true}}
+		getContentEncoding := func(path, acceptEncoding string) (string, error) {
+			req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s%s", haproxyInfo.PublicIP, path), nil)
+			if err != nil {
+				return "", err
+			}
+			if acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", acceptEncoding)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				return "", err
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return "", fmt.Errorf("unexpected status code %d", resp.StatusCode)
+			}
+			return resp.Header.Get("Content-Encoding"), nil
+		}
+
+		Eventually(func() error {
+			_, err := getContentEncoding("/compressed/page", "")
+			return err
+		}, 30*time.Second, time.Second).Should(Succeed())
+
+		for _, tc := range []struct {
+			path             string
+			acceptEncoding   string
+			expectedEncoding string
+		}{
+			{"/compressed/page", "", ""},
+			{"/compressed/page", "gzip", "gzip"},
+			{"/compressed/page", "deflate", "deflate"},
+			{"/compressed/page", "br", ""},
+			{"/compressed/page", "br, gzip", "gzip"},
+			{"/compressed/small", "gzip", ""},
+			{"/compressed/brotli", "br, gzip", "br"},
+			{"/compressed/login", "gzip", ""},
+			{"/uncompressed/page", "gzip", ""},
+		} {
+			By(fmt.Sprintf("Requesting %s with Accept-Encoding '%s', expecting Content-Encoding '%s'", tc.path, tc.acceptEncoding, tc.expectedEncoding))
+			Expect(getContentEncoding(tc.path, tc.acceptEncoding)).To(Equal(tc.expectedEncoding))
+		}
+
+		By("Checking that Accept-Encoding was removed for excluded paths, so that the backend does not compress either")
+		acceptEncoding, ok := backendAcceptEncoding.Load("/compressed/login")
+		Expect(ok).To(BeTrue())
+		Expect(acceptEncoding).To(BeEmpty())
+	})
+})
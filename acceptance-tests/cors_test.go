@@ -0,0 +1,113 @@
+package acceptance_tests
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CORS", func() {
+	opsfileCORS := `---
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/cors?
+  value:
+  - path_prefixes: [/api]
+    allowed_origins: [https://app.example.com]
+    allowed_origin_regexes: ['^https://[a-z]+\.example\.org$']
+    allowed_methods: [GET, PUT]
+    allowed_headers: [Content-Type]
+    exposed_headers: [X-Request-Id]
+    allow_credentials: true
+    max_age: 300
+`
+	var closeLocalServer func()
+	var closeTunnel func()
+	var backendRequests atomic.Int32
+	var baseURL string
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+	BeforeEach(func() {
+		haproxyBackendPort := 12000
+		haproxyInfo, _ := deployHAProxy(baseManifestVars{
+			haproxyBackendPort:    haproxyBackendPort,
+			haproxyBackendServers: []string{"127.0.0.1"},
+			deploymentName:        deploymentNameForTestNode(),
+		}, []string{opsfileCORS}, map[string]interface{}{}, true)
+		baseURL = fmt.Sprintf("http://%s", haproxyInfo.PublicIP)
+
+		By("Starting a local http server to act as a backend")
+		var localPort int
+		var err error
+		backendRequests.Store(0)
+		closeLocalServer, localPort, err = startLocalHTTPServer(nil, func(w http.ResponseWriter, r *http.Request) {
+			backendRequests.Add(1)
+			_, _ = w.Write([]byte("OK"))
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		closeTunnel = setupTunnelFromHaproxyToTestServer(haproxyInfo, haproxyBackendPort, localPort)
+	})
+
+	AfterEach(func() {
+		closeLocalServer()
+		closeTunnel()
+	})
+
+	sendRequest := func(method, path, origin string) *http.Response {
+		request, err := http.NewRequest(method, baseURL+path, nil)
+		Expect(err).NotTo(HaveOccurred())
+		request.Header.Set("Origin", origin)
+		if method == http.MethodOptions {
+			request.Header.Set("Access-Control-Request-Method", "PUT")
+		}
+		resp, err := client.Do(request)
+		Expect(err).NotTo(HaveOccurred())
+		return resp
+	}
+
+	It("Answers preflight requests without reaching the backend", func() {
+		for _, origin := range []string{"https://app.example.com", "https://docs.example.org"} {
+			By(fmt.Sprintf("Sending a preflight request from allowed origin %s", origin))
+			resp := sendRequest(http.MethodOptions, "/api/items", origin)
+			Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+			Expect(resp.Header.Get("Access-Control-Allow-Origin")).To(Equal(origin))
+			Expect(resp.Header.Get("Access-Control-Allow-Methods")).To(Equal("GET, PUT"))
+			Expect(resp.Header.Get("Access-Control-Allow-Headers")).To(Equal("Content-Type"))
+			Expect(resp.Header.Get("Access-Control-Allow-Credentials")).To(Equal("true"))
+			Expect(resp.Header.Get("Access-Control-Max-Age")).To(Equal("300"))
+		}
+
+		By("Sending a preflight request from a rejected origin")
+		resp := sendRequest(http.MethodOptions, "/api/items", "https://evil.example.net")
+		Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+		Expect(resp.Header).NotTo(HaveKey("Access-Control-Allow-Origin"))
+
+		Expect(backendRequests.Load()).To(BeZero())
+	})
+
+	It("Adds CORS headers to simple requests from allowed origins only", func() {
+		By("Sending a simple request from an allowed origin")
+		resp := sendRequest(http.MethodGet, "/api/items", "https://app.example.com")
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(resp.Header.Get("Access-Control-Allow-Origin")).To(Equal("https://app.example.com"))
+		Expect(resp.Header.Get("Access-Control-Allow-Credentials")).To(Equal("true"))
+		Expect(resp.Header.Get("Access-Control-Expose-Headers")).To(Equal("X-Request-Id"))
+		Expect(resp.Header.Values("Vary")).To(ContainElement("Origin"))
+
+		By("Sending a simple request from a rejected origin, expecting it to reach the backend without CORS headers")
+		resp = sendRequest(http.MethodGet, "/api/items", "https://evil.example.net")
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(resp.Header).NotTo(HaveKey("Access-Control-Allow-Origin"))
+		Expect(resp.Header).NotTo(HaveKey("Access-Control-Allow-Credentials"))
+
+		By("Sending a simple request outside of the policy's path prefixes")
+		resp = sendRequest(http.MethodGet, "/other", "https://app.example.com")
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(resp.Header).NotTo(HaveKey("Access-Control-Allow-Origin"))
+
+		Expect(backendRequests.Load()).To(Equal(int32(3)))
+	})
+})
@@ -0,0 +1,169 @@
+package acceptance_tests
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+var _ = Describe("Backend Discovery", func() {
+	haproxyBackendPort := 12000
+	dnsPort := 5353
+	opsfileDiscovery := fmt.Sprintf(`---
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/resolvers?
+  value:
+  - standin: tcp@127.0.0.1:%d
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/dns_hold?
+  value: 1s
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/backend_discovery?
+  value:
+    name: q-s0.backend.default.test.bosh
+    port: %d
+    slots: 3
+`, dnsPort, haproxyBackendPort)
+
+	It("Adds and removes servers as the DNS records change", func() {
+		haproxyInfo, _ := deployHAProxy(baseManifestVars{
+			haproxyBackendPort:    haproxyBackendPort,
+			haproxyBackendServers: []string{"127.0.0.1"},
+			deploymentName:        deploymentNameForTestNode(),
+		}, []string{opsfileDiscovery}, map[string]interface{}{}, true)
+
+		records := &dnsRecords{a: map[string][]net.IP{
+			"q-s0.backend.default.test.bosh.": {net.ParseIP("127.0.0.1")},
+		}}
+		closeDNSServer, localDNSPort := startTCPStandInServer(records.serve)
+		defer closeDNSServer()
+		closeDNSTunnel := setupTunnelFromHaproxyToTestServer(haproxyInfo, dnsPort, localDNSPort)
+		defer closeDNSTunnel()
+
+		for _, ip := range []string{"127.0.0.1", "127.0.0.2"} {
+			closeLocalServer, localPort := startDefaultTestServer(withIP(ip), withHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(ip))
+			}))
+			defer closeLocalServer()
+			closeTunnel := setupTunnelFromHaproxyIPToTestServerIP(haproxyInfo, ip, haproxyBackendPort, ip, localPort)
+			defer closeTunnel()
+		}
+
+		client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+		respondingServers := func() map[string]int {
+			servers := map[string]int{}
+			for i := 0; i < 10; i++ {
+				resp, err := client.Get(fmt.Sprintf("http://%s", haproxyInfo.PublicIP))
+				Expect(err).NotTo(HaveOccurred())
+				body, err := io.ReadAll(resp.Body)
+				Expect(err).NotTo(HaveOccurred())
+				resp.Body.Close()
+				servers[string(body)]++
+			}
+			return servers
+		}
+
+		By("Expecting the server of the initial record")
+		Eventually(respondingServers, 30*time.Second, time.Second).Should(Equal(map[string]int{"127.0.0.1": 10}))
+
+		By("Adding a record, expecting a second server")
+		records.set("q-s0.backend.default.test.bosh.", "127.0.0.1", "127.0.0.2")
+		Eventually(respondingServers, 30*time.Second, time.Second).Should(And(HaveKey("127.0.0.1"), HaveKey("127.0.0.2")))
+
+		By("Removing the first record, expecting its server to disappear")
+		records.set("q-s0.backend.default.test.bosh.", "127.0.0.2")
+		Eventually(respondingServers, 30*time.Second, time.Second).Should(Equal(map[string]int{"127.0.0.2": 10}))
+	})
+})
+
+// dnsRecords is a DNS stand-in server answering A queries over TCP, where each message is prefixed with its length.
+type dnsRecords struct {
+	mutex sync.Mutex
+	a     map[string][]net.IP
+}
+
+func (d *dnsRecords) set(name string, ips ...string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.a[name] = nil
+	for _, ip := range ips {
+		d.a[name] = append(d.a[name], net.ParseIP(ip))
+	}
+}
+
+// serve answers the queries of a connection until it is closed. HAProxy keeps the connection open for further queries.
+func (d *dnsRecords) serve(conn net.Conn) {
+	for {
+		_ = conn.SetDeadline(time.Now().Add(time.Minute))
+		var length uint16
+		if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+			return
+		}
+		query := make([]byte, length)
+		if _, err := io.ReadFull(conn, query); err != nil {
+			return
+		}
+		response, err := d.answer(query)
+		if err != nil {
+			return
+		}
+		if err := binary.Write(conn, binary.BigEndian, uint16(len(response))); err != nil {
+			return
+		}
+		if _, err := conn.Write(response); err != nil {
+			return
+		}
+	}
+}
+
+func (d *dnsRecords) answer(query []byte) ([]byte, error) {
+	var parser dnsmessage.Parser
+	header, err := parser.Start(query)
+	if err != nil {
+		return nil, err
+	}
+	question, err := parser.Question()
+	if err != nil {
+		return nil, err
+	}
+
+	d.mutex.Lock()
+	ips, known := d.a[question.Name.String()]
+	d.mutex.Unlock()
+
+	rcode := dnsmessage.RCodeSuccess
+	if !known {
+		rcode = dnsmessage.RCodeNameError
+	}
+	builder := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: header.ID, Response: true, Authoritative: true, RCode: rcode})
+	builder.EnableCompression()
+	if err := builder.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := builder.Question(question); err != nil {
+		return nil, err
+	}
+	if err := builder.StartAnswers(); err != nil {
+		return nil, err
+	}
+	// AAAA queries are answered without records, so that HAProxy uses the A records
+	if question.Type == dnsmessage.TypeA {
+		for _, ip := range ips {
+			resource := dnsmessage.AResource{}
+			copy(resource.A[:], ip.To4())
+			answerHeader := dnsmessage.ResourceHeader{Name: question.Name, Class: dnsmessage.ClassINET, TTL: 1}
+			if err := builder.AResource(answerHeader, resource); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return builder.Finish()
+}
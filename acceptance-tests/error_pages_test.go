@@ -0,0 +1,117 @@
+package acceptance_tests
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Error Pages", func() {
+	opsfileErrorPages := `---
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/routed_backend_servers?
+  value:
+    /api:
+      servers: [127.0.0.1]
+      port: 12001
+    /down:
+      # Nothing listens on this port, so HAProxy generates a 503
+      servers: [127.0.0.1]
+      port: 12002
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/error_pages?
+  value:
+  - statuses: [503]
+    html: '<h1>Error {{status}}</h1><p>{{request_id}}</p>'
+  - statuses: [404]
+    hosts: [shop.example.com]
+    intercept_backend_errors: true
+    html: '<h1>Not found in the shop</h1>'
+    json: '{"error":"not found","request_id":"{{request_id}}"}'
+  - statuses: [500]
+    routed_backends: [/api]
+    intercept_backend_errors: true
+    json: '{"error":"api failed","status":{{status}}}'
+`
+	It("Serves the page for the route, host and Accept header", func() {
+		haproxyBackendPort := 12000
+		haproxyInfo, _ := deployHAProxy(baseManifestVars{
+			haproxyBackendPort:    haproxyBackendPort,
+			haproxyBackendServers: []string{"127.0.0.1"},
+			deploymentName:        deploymentNameForTestNode(),
+		}, []string{opsfileErrorPages}, map[string]interface{}{}, true)
+
+		By("Starting a local http server that fails for some paths")
+		closeLocalServer, localPort, err := startLocalHTTPServer(nil, func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/missing":
+				http.Error(w, "backend 404", http.StatusNotFound)
+			case "/api/fail":
+				http.Error(w, "backend 500", http.StatusInternalServerError)
+			default:
+				_, _ = w.Write([]byte("OK"))
+			}
+		})
+		Expect(err).NotTo(HaveOccurred())
+		defer closeLocalServer()
+
+		closeTunnel := setupTunnelFromHaproxyToTestServer(haproxyInfo, haproxyBackendPort, localPort)
+		defer closeTunnel()
+		closeAPITunnel := setupTunnelFromHaproxyToTestServer(haproxyInfo, 12001, localPort)
+		defer closeAPITunnel()
+
+		client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+		sendRequest := func(host, path, accept string) (int, string, string) {
+			request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s%s", haproxyInfo.PublicIP, path), nil)
+			Expect(err).NotTo(HaveOccurred())
+			if host != "" {
+				request.Host = host
+			}
+			if accept != "" {
+				request.Header.Set("Accept", accept)
+			}
+			request.Header.Set("X-Request-Id", "test-request-1")
+			resp, err := client.Do(request)
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			Expect(err).NotTo(HaveOccurred())
+			return resp.StatusCode, resp.Header.Get("Content-Type"), string(body)
+		}
+
+		By("Serving the global page for errors generated by HAProxy")
+		Eventually(func() int {
+			status, _, _ := sendRequest("", "/down", "")
+			return status
+		}, 30*time.Second, time.Second).Should(Equal(http.StatusServiceUnavailable))
+		status, contentType, body := sendRequest("", "/down", "")
+		Expect(status).To(Equal(http.StatusServiceUnavailable))
+		Expect(contentType).To(Equal("text/html; charset=utf-8"))
+		Expect(body).To(Equal("<h1>Error 503</h1><p>test-request-1</p>\n"))
+
+		By("Serving the host page as HTML or JSON depending on the Accept header")
+		status, contentType, body = sendRequest("shop.example.com", "/missing", "text/html,application/xhtml+xml")
+		Expect(status).To(Equal(http.StatusNotFound))
+		Expect(contentType).To(Equal("text/html; charset=utf-8"))
+		Expect(body).To(Equal("<h1>Not found in the shop</h1>\n"))
+		status, contentType, body = sendRequest("shop.example.com", "/missing", "application/json")
+		Expect(status).To(Equal(http.StatusNotFound))
+		Expect(contentType).To(Equal("application/json"))
+		Expect(body).To(Equal(`{"error":"not found","request_id":"test-request-1"}` + "\n"))
+
+		By("Passing backend errors of other hosts through")
+		status, _, body = sendRequest("other.example.com", "/missing", "")
+		Expect(status).To(Equal(http.StatusNotFound))
+		Expect(body).To(ContainSubstring("backend 404"))
+
+		By("Serving the page of the routed backend")
+		status, contentType, body = sendRequest("", "/api/fail", "")
+		Expect(status).To(Equal(http.StatusInternalServerError))
+		Expect(contentType).To(Equal("application/json"))
+		Expect(body).To(Equal(`{"error":"api failed","status":500}` + "\n"))
+	})
+})
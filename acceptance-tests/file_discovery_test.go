@@ -0,0 +1,88 @@
+package acceptance_tests
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Backend Discovery from Files", func() {
+	haproxyBackendPort := 12000
+	endpointsFile := "/var/vcap/data/haproxy-discovery/routers.json"
+	opsfileDiscoveryFile := fmt.Sprintf(`---
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/backend_discovery_file?
+  value: %s
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/backend_discovery_file_slots?
+  value: 2
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/backend_discovery_file_interval?
+  value: 1
+`, endpointsFile)
+
+	It("Reconciles the servers with the endpoints of the file", func() {
+		haproxyInfo, _ := deployHAProxy(baseManifestVars{
+			haproxyBackendPort:    haproxyBackendPort,
+			haproxyBackendServers: []string{"127.0.0.1"},
+			deploymentName:        deploymentNameForTestNode(),
+		}, []string{opsfileDiscoveryFile}, map[string]interface{}{}, true)
+
+		for _, ip := range []string{"127.0.0.1", "127.0.0.2"} {
+			closeLocalServer, localPort := startDefaultTestServer(withIP(ip), withHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(ip))
+			}))
+			defer closeLocalServer()
+			closeTunnel := setupTunnelFromHaproxyIPToTestServerIP(haproxyInfo, ip, haproxyBackendPort, ip, localPort)
+			defer closeTunnel()
+		}
+
+		// Only vcap and the members of ha_proxy.discovery_file_group may write to the directory of the file
+		writeEndpoints := func(content string) {
+			_, _, err := runOnRemote(haproxyInfo.SSHUser, haproxyInfo.PublicIP, haproxyInfo.SSHPrivateKey,
+				fmt.Sprintf("echo '%s' | sudo -u vcap tee %s", content, endpointsFile))
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+		respondingServers := func() map[string]int {
+			servers := map[string]int{}
+			for i := 0; i < 10; i++ {
+				resp, err := client.Get(fmt.Sprintf("http://%s", haproxyInfo.PublicIP))
+				Expect(err).NotTo(HaveOccurred())
+				body, err := io.ReadAll(resp.Body)
+				Expect(err).NotTo(HaveOccurred())
+				resp.Body.Close()
+				servers[string(body)]++
+			}
+			return servers
+		}
+
+		By("Writing the first endpoint, expecting it to be added")
+		writeEndpoints(`{"servers": [{"address": "127.0.0.1"}]}`)
+		Eventually(respondingServers, 30*time.Second, time.Second).Should(Equal(map[string]int{"127.0.0.1": 10}))
+
+		By("Adding a second endpoint")
+		writeEndpoints(`{"servers": [{"address": "127.0.0.1"}, {"address": "127.0.0.2", "port": 12000}]}`)
+		Eventually(respondingServers, 30*time.Second, time.Second).Should(And(HaveKey("127.0.0.1"), HaveKey("127.0.0.2")))
+
+		By("Writing an invalid file, expecting the servers to be kept")
+		writeEndpoints(`{"servers": [{"address": "router.example.com"}]}`)
+		Consistently(respondingServers, 5*time.Second, time.Second).Should(And(HaveKey("127.0.0.1"), HaveKey("127.0.0.2")))
+
+		By("Removing the first endpoint, expecting its server to be removed")
+		writeEndpoints(`{"servers": [{"address": "127.0.0.2"}]}`)
+		Eventually(respondingServers, 30*time.Second, time.Second).Should(Equal(map[string]int{"127.0.0.2": 10}))
+
+		By("Expecting the changes to be logged as drift")
+		stdout, _, err := runOnRemote(haproxyInfo.SSHUser, haproxyInfo.PublicIP, haproxyInfo.SSHPrivateKey,
+			"sudo cat /var/vcap/sys/log/haproxy/file-discovery.stderr.log")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stdout).To(ContainSubstring(fmt.Sprintf("Drift from %s: removed 127.0.0.1:12000 from http-routers-http1/node0", endpointsFile)))
+		Expect(stdout).To(ContainSubstring("is not an IP address"))
+	})
+})
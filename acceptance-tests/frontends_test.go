@@ -0,0 +1,84 @@
+package acceptance_tests
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+/*
+Test strategy:
+  - Requests through an SSH tunnel appear to come from 127.0.0.1 on the HAProxy VM
+  - Requests directly from the test runner appear to come from 10.0.0.0/8
+    The public frontend blocks 127.0.0.1, the internal frontend only allows it
+*/
+var _ = Describe("Named Frontends", func() {
+	opsfileFrontends := `---
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/frontends?
+  value:
+  - name: public
+    port: 8080
+    cidr_blacklist: [127.0.0.1/32]
+    headers:
+      X-Frontend: public
+  - name: internal
+    port: 8081
+    cidr_whitelist: [127.0.0.1/32]
+    block_all: true
+    headers:
+      X-Frontend: internal
+`
+
+	It("Applies the policies of each frontend to its own listener only", func() {
+		haproxyBackendPort := 12000
+		haproxyInfo, _ := deployHAProxy(baseManifestVars{
+			haproxyBackendPort:    haproxyBackendPort,
+			haproxyBackendServers: []string{"127.0.0.1"},
+			deploymentName:        deploymentNameForTestNode(),
+		}, []string{opsfileFrontends}, map[string]interface{}{}, true)
+
+		closeLocalServer, localPort := startDefaultTestServer(withHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(r.Header.Get("X-Frontend")))
+		}))
+		defer closeLocalServer()
+
+		closeBackendTunnel := setupTunnelFromHaproxyToTestServer(haproxyInfo, haproxyBackendPort, localPort)
+		defer closeBackendTunnel()
+
+		closePublicTunnel := setupTunnelFromLocalMachineToHAProxy(haproxyInfo, 11000, 8080)
+		defer closePublicTunnel()
+		closeInternalTunnel := setupTunnelFromLocalMachineToHAProxy(haproxyInfo, 11001, 8081)
+		defer closeInternalTunnel()
+
+		expectFrontend := func(url, frontend string) {
+			resp, err := http.Get(url)
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			body, err := io.ReadAll(resp.Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(body)).To(Equal(frontend))
+		}
+
+		By("Allowing the test runner on the public frontend")
+		expectFrontend(fmt.Sprintf("http://%s:8080", haproxyInfo.PublicIP), "public")
+
+		By("Denying the test runner on the internal frontend")
+		_, err := http.Get(fmt.Sprintf("http://%s:8081", haproxyInfo.PublicIP))
+		Expect(err).To(HaveOccurred())
+		Expect(errors.Is(err, io.EOF)).To(BeTrue())
+
+		By("Allowing 127.0.0.1 on the internal frontend")
+		expectFrontend("http://127.0.0.1:11001", "internal")
+
+		By("Denying 127.0.0.1 on the public frontend")
+		_, err = http.Get("http://127.0.0.1:11000")
+		Expect(err).To(HaveOccurred())
+		Expect(errors.Is(err, io.EOF)).To(BeTrue())
+	})
+})
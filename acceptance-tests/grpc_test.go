@@ -0,0 +1,102 @@
+package acceptance_tests
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+var _ = Describe("gRPC Backends", func() {
+	opsfileGRPC := `---
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/routed_backend_servers?
+  value:
+    /grpc.health.v1.Health:
+      servers: [127.0.0.1]
+      port: ((grpc_backend_port))
+      backend_protocol: grpc
+      backend_use_grpc_health: true
+`
+
+	It("Proxies unary and streaming gRPC calls and checks the gRPC health of the servers", func() {
+		grpcBackendPort := 12001
+		haproxyInfo, _ := deployHAProxy(baseManifestVars{
+			haproxyBackendPort:    12000,
+			haproxyBackendServers: []string{"127.0.0.1"},
+			deploymentName:        deploymentNameForTestNode(),
+		}, []string{opsfileGRPC}, map[string]interface{}{
+			"grpc_backend_port": grpcBackendPort,
+		}, true)
+
+		healthServer, closeGRPCServer, localPort := startGRPCHealthServer()
+		defer closeGRPCServer()
+
+		closeTunnel := setupTunnelFromHaproxyToTestServer(haproxyInfo, grpcBackendPort, localPort)
+		defer closeTunnel()
+
+		By("Connecting to HAProxy using h2c")
+		conn, err := grpc.NewClient(fmt.Sprintf("%s:80", haproxyInfo.PublicIP), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		Expect(err).NotTo(HaveOccurred())
+		defer conn.Close()
+		client := healthpb.NewHealthClient(conn)
+
+		By("Making a unary call, expecting the status from the backend")
+		Eventually(func() (healthpb.HealthCheckResponse_ServingStatus, error) {
+			resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+			return resp.GetStatus(), err
+		}, 30*time.Second, time.Second).Should(Equal(healthpb.HealthCheckResponse_SERVING))
+
+		By("Making a call for an unknown service, expecting the gRPC status code from the trailers")
+		_, err = client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "unknown"})
+		Expect(status.Code(err)).To(Equal(codes.NotFound))
+
+		By("Making a streaming call, expecting updates to be streamed")
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{Service: "orders"})
+		Expect(err).NotTo(HaveOccurred())
+
+		resp, err := stream.Recv()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.GetStatus()).To(Equal(healthpb.HealthCheckResponse_SERVICE_UNKNOWN))
+
+		healthServer.SetServingStatus("orders", healthpb.HealthCheckResponse_SERVING)
+		resp, err = stream.Recv()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.GetStatus()).To(Equal(healthpb.HealthCheckResponse_SERVING))
+
+		By("Setting the server to NOT_SERVING, expecting the health check to take it out of rotation")
+		healthServer.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+		Eventually(func() codes.Code {
+			_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+			return status.Code(err)
+		}, 30*time.Second, time.Second).Should(Equal(codes.Unavailable))
+	})
+})
+
+// startGRPCHealthServer starts a local gRPC server which only implements the health checking protocol,
+// which offers both unary and server streaming calls. The returned health server changes the reported status.
+func startGRPCHealthServer() (*health.Server, func(), int) {
+	By("Starting a local gRPC server to act as a backend")
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).NotTo(HaveOccurred())
+
+	server := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(server, healthServer)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+
+	return healthServer, server.Stop, listener.Addr().(*net.TCPAddr).Port
+}
@@ -19,6 +19,41 @@ var _ = Describe("Headers", func() {
   value: 
     Custom-Header-To-Add: add-value
     Custom-Header-To-Replace: replace-value
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/security_headers?
+  value:
+    enabled: true
+    csp_report_only: true
+    headers:
+      Permissions-Policy: ""
+    host_overrides:
+      embed.haproxy.internal:
+        X-Frame-Options: ""
+        Referrer-Policy: no-referrer
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/header_rules?
+  value:
+  - action: set
+    name: X-Rule-Path
+    value: rules
+    conditions:
+      paths: [/rules]
+      methods: [GET]
+  - action: add
+    name: X-Rule-Token
+    value: present
+    conditions:
+      header_present: X-Token
+  - action: del
+    direction: response
+    name: X-Backend-Secret
+  - action: replace-value
+    direction: response
+    name: X-Backend-Version
+    regex: '^v([0-9]+)$'
+    replacement: 'version-\1'
+    conditions:
+      paths: [/rules]
 # Configure CA and cert chain
 - type: replace
   path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/crt_list?/-
@@ -78,6 +113,11 @@ var _ = Describe("Headers", func() {
 		closeLocalServer, localPort, err = startLocalHTTPServer(nil, func(w http.ResponseWriter, r *http.Request) {
 			writeLog("Backend server handling incoming request")
 			recordedHeaders = r.Header
+			if r.URL.Path == "/backend-headers" {
+				w.Header().Set("X-Frame-Options", "DENY")
+			}
+			w.Header().Set("X-Backend-Secret", "secret")
+			w.Header().Set("X-Backend-Version", "v2")
 			_, _ = w.Write([]byte("OK"))
 		})
 		Expect(err).NotTo(HaveOccurred())
@@ -149,4 +189,65 @@ var _ = Describe("Headers", func() {
 
 	})
 
+	It("Adds security headers unless the backend set them", func() {
+		getResponseHeaders := func(host, path string) http.Header {
+			request, err := http.NewRequest("GET", "https://haproxy.internal:443"+path, nil)
+			Expect(err).NotTo(HaveOccurred())
+			request.Host = host
+			resp, err := client.Do(request)
+			expect200(resp, err)
+			return resp.Header
+		}
+
+		By("Sending a request, expecting the configured preset")
+		headers := getResponseHeaders("haproxy.internal", "/")
+		Expect(headers.Get("Content-Security-Policy-Report-Only")).To(Equal("default-src 'self'; frame-ancestors 'self'; object-src 'none'"))
+		Expect(headers).NotTo(HaveKey("Content-Security-Policy"))
+		Expect(headers.Get("X-Frame-Options")).To(Equal("SAMEORIGIN"))
+		Expect(headers.Get("X-Content-Type-Options")).To(Equal("nosniff"))
+		Expect(headers.Get("Referrer-Policy")).To(Equal("strict-origin-when-cross-origin"))
+		Expect(headers).NotTo(HaveKey("Permissions-Policy"))
+
+		By("Sending a request to a path where the backend sets X-Frame-Options, expecting the backend's value")
+		headers = getResponseHeaders("haproxy.internal", "/backend-headers")
+		Expect(headers.Values("X-Frame-Options")).To(Equal([]string{"DENY"}))
+
+		By("Sending a request for a host with overrides, expecting the headers of the host")
+		headers = getResponseHeaders("embed.haproxy.internal", "/")
+		Expect(headers).NotTo(HaveKey("X-Frame-Options"))
+		Expect(headers.Get("Referrer-Policy")).To(Equal("no-referrer"))
+		Expect(headers.Get("X-Content-Type-Options")).To(Equal("nosniff"))
+	})
+
+	It("Applies header rules matching their conditions", func() {
+		sendRequest := func(method, path string, requestHeaders map[string]string) http.Header {
+			request, err := http.NewRequest(method, "https://haproxy.internal:443"+path, nil)
+			Expect(err).NotTo(HaveOccurred())
+			for key, value := range requestHeaders {
+				request.Header.Set(key, value)
+			}
+			resp, err := client.Do(request)
+			expect200(resp, err)
+			return resp.Header
+		}
+
+		By("Sending a request matching the path and method conditions")
+		responseHeaders := sendRequest("GET", "/rules", map[string]string{"X-Token": "abc"})
+		Expect(recordedHeaders.Get("X-Rule-Path")).To(Equal("rules"))
+		Expect(recordedHeaders.Get("X-Rule-Token")).To(Equal("present"))
+		Expect(responseHeaders).NotTo(HaveKey("X-Backend-Secret"))
+		Expect(responseHeaders.Get("X-Backend-Version")).To(Equal("version-2"))
+
+		By("Sending a request not matching the method and header conditions")
+		responseHeaders = sendRequest("POST", "/rules", nil)
+		Expect(recordedHeaders).NotTo(HaveKey("X-Rule-Path"))
+		Expect(recordedHeaders).NotTo(HaveKey("X-Rule-Token"))
+		Expect(responseHeaders.Get("X-Backend-Version")).To(Equal("version-2"))
+
+		By("Sending a request not matching the path condition")
+		responseHeaders = sendRequest("GET", "/", nil)
+		Expect(recordedHeaders).NotTo(HaveKey("X-Rule-Path"))
+		Expect(responseHeaders).NotTo(HaveKey("X-Backend-Secret"))
+		Expect(responseHeaders.Get("X-Backend-Version")).To(Equal("v2"))
+	})
 })
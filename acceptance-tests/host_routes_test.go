@@ -0,0 +1,106 @@
+package acceptance_tests
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Host Routes", func() {
+	var haproxyInfo haproxyInfo
+	var closeTunnel []func()
+	var closeLocalServer []func()
+
+	haproxyBackendPort := 12000
+	opsfileHostRoutes := `---
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/host_routes?
+  value:
+    app.example.com:
+      servers: [127.0.0.2]
+      port: 12000
+    "*.wild.example.com":
+      servers: [127.0.0.3]
+      port: 12000
+`
+
+	BeforeEach(func() {
+		haproxyInfo, _ = deployHAProxy(baseManifestVars{
+			haproxyBackendPort:    haproxyBackendPort,
+			haproxyBackendServers: []string{"127.0.0.1"},
+			deploymentName:        deploymentNameForTestNode(),
+		}, []string{opsfileHostRoutes}, map[string]interface{}{}, true)
+
+		closeTunnel = []func(){}
+		closeLocalServer = []func(){}
+		for _, backendIP := range []string{"127.0.0.1", "127.0.0.2", "127.0.0.3"} {
+			closeLocalServerFunc, localPort := startNamedTestServer(backendIP)
+			closeLocalServer = append(closeLocalServer, closeLocalServerFunc)
+			closeTunnel = append(closeTunnel, setupTunnelFromHaproxyIPToTestServerIP(haproxyInfo, backendIP, haproxyBackendPort, "127.0.0.1", localPort))
+		}
+	})
+
+	AfterEach(func() {
+		for _, closeLocalServerFunc := range closeLocalServer {
+			closeLocalServerFunc()
+		}
+		for _, closeTunnelFunc := range closeTunnel {
+			closeTunnelFunc()
+		}
+	})
+
+	It("Routes requests to the backend of the matching host", func() {
+		By("Sending a request for an exact host")
+		Expect(getServerNameForHost(haproxyInfo, "app.example.com")).To(Equal("127.0.0.2"))
+
+		By("Sending requests for hosts matching the wildcard")
+		Expect(getServerNameForHost(haproxyInfo, "foo.wild.example.com")).To(Equal("127.0.0.3"))
+		Expect(getServerNameForHost(haproxyInfo, "foo.bar.wild.example.com")).To(Equal("127.0.0.3"))
+
+		By("Sending a request for an unknown host, expecting the default backend")
+		Expect(getServerNameForHost(haproxyInfo, "unknown.example.com")).To(Equal("127.0.0.1"))
+	})
+
+	It("Allows host routes to be changed at runtime", func() {
+		mapFile := "/var/vcap/jobs/haproxy/config/host_routes.map"
+
+		By("Removing the exact host from the map, expecting the default backend")
+		runHAProxySocketCommand(haproxyInfo, fmt.Sprintf("del map %s app.example.com", mapFile))
+		Expect(getServerNameForHost(haproxyInfo, "app.example.com")).To(Equal("127.0.0.1"))
+
+		By("Adding the exact host pointing to the wildcard backend")
+		// The map values are the keys of the routes, which name their backends, i.e. http-host-backend-.wild.example.com
+		runHAProxySocketCommand(haproxyInfo, fmt.Sprintf("add map %s app.example.com .wild.example.com", mapFile))
+		Expect(getServerNameForHost(haproxyInfo, "app.example.com")).To(Equal("127.0.0.3"))
+	})
+})
+
+// getServerNameForHost sends a request with the given Host header to HAProxy and returns the name
+// of the server started with startNamedTestServer that handled it
+func getServerNameForHost(haproxyInfo haproxyInfo, host string) (string, error) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("http://%s", haproxyInfo.PublicIP), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Host = host
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("expected status code 200, got %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	return string(body), nil
+}
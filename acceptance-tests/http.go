@@ -13,6 +13,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
 	"golang.org/x/net/http2"
 )
 
@@ -94,6 +96,23 @@ func buildHTTP2Client(caCerts []string, addressMap map[string]string, clientCert
 	return &http.Client{Transport: transport}
 }
 
+// Build an HTTP3 client with custom CA certificate pool which resolves hosts based on provided map
+func buildHTTP3Client(caCerts []string, addressMap map[string]string, clientCerts []tls.Certificate) *http.Client {
+	transport := &http3.Transport{
+		TLSClientConfig: buildTLSConfig(caCerts, clientCerts, ""),
+		// Override Dial to force resolve with alternative addresses
+		Dial: func(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (*quic.Conn, error) {
+			if altAddr, ok := addressMap[strings.ToLower(addr)]; ok {
+				addr = altAddr
+			}
+
+			return quic.DialAddrEarly(ctx, addr, tlsCfg, cfg)
+		},
+	}
+
+	return &http.Client{Transport: transport, Timeout: 30 * time.Second}
+}
+
 func connectTLSALPNNegotiatedProtocol(protos []string, publicIP string, ca string, sni string) (string, error) {
 	config := buildTLSConfig([]string{ca}, []tls.Certificate{}, sni)
 	config.NextProtos = protos
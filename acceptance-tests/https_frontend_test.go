@@ -15,8 +15,10 @@ var _ = Describe("HTTPS Frontend", func() {
 	var closeTunnel func()
 	var closeLocalServer func()
 	enableHTTP2 := false
+	enableHTTP3 := false
 	var http1Client *http.Client
 	var http2Client *http.Client
+	var http3Client *http.Client
 
 	haproxyBackendPort := 12000
 	opsfileHTTPS := `---
@@ -24,6 +26,10 @@ var _ = Describe("HTTPS Frontend", func() {
 - type: replace
   path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/enable_http2?
   value: ((enable_http2))
+# Configure HTTP3
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/enable_http3?
+  value: ((enable_http3))
 # Configure CA and cert chain
 - type: replace
   path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/crt_list?/-
@@ -99,6 +105,7 @@ var _ = Describe("HTTPS Frontend", func() {
 			deploymentName:        deploymentNameForTestNode(),
 		}, []string{opsfileHTTPS}, map[string]interface{}{
 			"enable_http2": enableHTTP2,
+			"enable_http3": enableHTTP3,
 		}, true)
 
 		err := varsStoreReader(&creds)
@@ -116,6 +123,7 @@ var _ = Describe("HTTPS Frontend", func() {
 
 		http1Client = buildHTTPClient([]string{creds.HTTPSFrontend.CA}, addresses, []tls.Certificate{}, "")
 		http2Client = buildHTTP2Client([]string{creds.HTTPSFrontend.CA}, addresses, []tls.Certificate{})
+		http3Client = buildHTTP3Client([]string{creds.HTTPSFrontend.CA}, addresses, []tls.Certificate{})
 	})
 
 	AfterEach(func() {
@@ -185,6 +193,38 @@ var _ = Describe("HTTPS Frontend", func() {
 		})
 	})
 
+	Context("When ha_proxy.enable_http3 is true", func() {
+		BeforeEach(func() {
+			enableHTTP2 = true
+			enableHTTP3 = true
+		})
+
+		AfterEach(func() {
+			enableHTTP3 = false
+		})
+
+		It("Allows clients to use HTTP3 as well as HTTP2", func() {
+			By("Sending a request to HAProxy using HTTP 2, expecting HTTP3 to be advertised")
+			resp, err := http2Client.Get("https://haproxy.internal:443")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(resp.ProtoMajor).To(Equal(2))
+			Expect(resp.Header.Get("Alt-Svc")).To(Equal(`h3=":443"; ma=86400`))
+
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Eventually(gbytes.BufferReader(resp.Body)).Should(gbytes.Say("Hello cloud foundry"))
+
+			By("Sending a request to HAProxy using HTTP 3")
+			resp, err = http3Client.Get("https://haproxy.internal:443")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(resp.ProtoMajor).To(Equal(3))
+
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Eventually(gbytes.BufferReader(resp.Body)).Should(gbytes.Say("Hello cloud foundry"))
+		})
+	})
+
 	Context("ALPN Configuration via CRT list", func() {
 		BeforeEach(func() {
 			// Do not enable HTTP globally, since we are adding it via crt-list entries
@@ -0,0 +1,69 @@
+package acceptance_tests
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+/*
+Test strategy:
+  - HAProxy binds 0.0.0.0 and :: next to each other, the backend server is ::1
+  - Requests through an SSH tunnel to ::1 appear to come from ::1 on the HAProxy VM
+  - Requests directly from the test runner appear to come from 10.0.0.0/8
+    Only ::1 is whitelisted, so the IPv4 requests are rejected
+*/
+var _ = Describe("IPv6", func() {
+	opsfileIPv6 := `---
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/binding_ips?
+  value: [0.0.0.0, "::"]
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/cidr_whitelist?
+  value: ["::1/128"]
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/block_all?
+  value: true
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/true_client_ip_header?
+  value: X-Cf-True-Client-Ip
+`
+
+	It("Accepts IPv6 clients and proxies to IPv6 backend servers", func() {
+		haproxyBackendPort := 12000
+		haproxyInfo, _ := deployHAProxy(baseManifestVars{
+			haproxyBackendPort:    haproxyBackendPort,
+			haproxyBackendServers: []string{"::1"},
+			deploymentName:        deploymentNameForTestNode(),
+		}, []string{opsfileIPv6}, map[string]interface{}{}, true)
+
+		closeLocalServer, localPort := startDefaultTestServer(withIP("::1"), withHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(r.Header.Get("X-Cf-True-Client-Ip")))
+		}))
+		defer closeLocalServer()
+
+		closeBackendTunnel := setupTunnelFromHaproxyIPToTestServerIP(haproxyInfo, "::1", haproxyBackendPort, "::1", localPort)
+		defer closeBackendTunnel()
+
+		closeTunnel := setupTunnelFromLocalMachineIPToHAProxyIP(haproxyInfo, "::1", 11000, "::1", 80)
+		defer closeTunnel()
+
+		By("Allowing ::1 and passing it on as the true client IP")
+		resp, err := http.Get("http://[::1]:11000")
+		Expect(err).NotTo(HaveOccurred())
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		body, err := io.ReadAll(resp.Body)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(body)).To(Equal("::1"))
+
+		By("Denying IPv4 clients which are not whitelisted")
+		_, err = http.Get(fmt.Sprintf("http://%s", haproxyInfo.PublicIP))
+		Expect(err).To(HaveOccurred())
+		Expect(errors.Is(err, io.EOF)).To(BeTrue())
+	})
+})
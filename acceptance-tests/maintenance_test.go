@@ -0,0 +1,80 @@
+package acceptance_tests
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Maintenance mode", func() {
+	opsfileMaintenance := `---
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/maintenance?
+  value:
+    enabled: true
+    path_prefixes: [/reports]
+    retry_after: 120
+    content_type: application/json
+    page: '{"status":"maintenance"}'
+`
+	It("Serves the maintenance page and can be switched at runtime", func() {
+		haproxyBackendPort := 12000
+		haproxyInfo, _ := deployHAProxy(baseManifestVars{
+			haproxyBackendPort:    haproxyBackendPort,
+			haproxyBackendServers: []string{"127.0.0.1"},
+			deploymentName:        deploymentNameForTestNode(),
+		}, []string{opsfileMaintenance}, map[string]interface{}{}, true)
+
+		closeLocalServer, localPort := startDefaultTestServer()
+		defer closeLocalServer()
+
+		closeTunnel := setupTunnelFromHaproxyToTestServer(haproxyInfo, haproxyBackendPort, localPort)
+		defer closeTunnel()
+
+		client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+		sendRequest := func(host, path string) (*http.Response, error) {
+			request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s%s", haproxyInfo.PublicIP, path), nil)
+			Expect(err).NotTo(HaveOccurred())
+			if host != "" {
+				request.Host = host
+			}
+			return client.Do(request)
+		}
+		expectMaintenance := func(resp *http.Response, err error) {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusServiceUnavailable))
+			Expect(resp.Header.Get("Retry-After")).To(Equal("120"))
+			Expect(resp.Header.Get("Content-Type")).To(Equal("application/json"))
+			body, err := io.ReadAll(resp.Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(body)).To(Equal(`{"status":"maintenance"}`))
+		}
+
+		By("Serving the maintenance page for path prefixes from the manifest")
+		expectMaintenance(sendRequest("", "/reports/monthly"))
+		expectTestServer200(sendRequest("", "/other"))
+
+		By("Switching on maintenance for all requests at runtime")
+		runHAProxySocketCommand(haproxyInfo, "experimental-mode on; set var proc.maintenance_all bool(true)")
+		expectMaintenance(sendRequest("", "/other"))
+
+		By("Letting requests from bypass CIDRs through")
+		runHAProxySocketCommand(haproxyInfo, "add acl /var/vcap/jobs/haproxy/config/maintenance_bypass_cidrs.txt 0.0.0.0/0")
+		expectTestServer200(sendRequest("", "/other"))
+		runHAProxySocketCommand(haproxyInfo, "del acl /var/vcap/jobs/haproxy/config/maintenance_bypass_cidrs.txt 0.0.0.0/0")
+
+		By("Switching off maintenance for all requests at runtime")
+		runHAProxySocketCommand(haproxyInfo, "experimental-mode on; set var proc.maintenance_all bool(false)")
+		expectTestServer200(sendRequest("", "/other"))
+
+		By("Switching maintenance for a single host on and off at runtime")
+		runHAProxySocketCommand(haproxyInfo, "add acl /var/vcap/jobs/haproxy/config/maintenance_hosts.txt app.example.com")
+		expectMaintenance(sendRequest("app.example.com", "/other"))
+		expectTestServer200(sendRequest("other.example.com", "/other"))
+		runHAProxySocketCommand(haproxyInfo, "del acl /var/vcap/jobs/haproxy/config/maintenance_hosts.txt app.example.com")
+		expectTestServer200(sendRequest("app.example.com", "/other"))
+	})
+})
@@ -5,6 +5,7 @@ import (
 	"net"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -150,17 +151,27 @@ func performProxyProtocolRequestWithSourceIP(ip string, port int, endpoint strin
 
 	defer conn.Close()
 
-	// Create proxy protocol header
+	// Create proxy protocol header, source and destination must be of the same address family
+	transportProtocol := proxyproto.TCPv4
+	destinationIP := net.ParseIP(ip)
+	if net.ParseIP(sourceIP).To4() == nil {
+		transportProtocol = proxyproto.TCPv6
+		if destinationIP.To4() != nil {
+			destinationIP = net.IPv6loopback
+		}
+	} else if destinationIP.To4() == nil {
+		destinationIP = net.IPv4(127, 0, 0, 1)
+	}
 	header := &proxyproto.Header{
 		Version:           1,
 		Command:           proxyproto.PROXY,
-		TransportProtocol: proxyproto.TCPv4,
+		TransportProtocol: transportProtocol,
 		SourceAddr: &net.TCPAddr{
 			IP:   net.ParseIP(sourceIP),
 			Port: 1000,
 		},
 		DestinationAddr: &net.TCPAddr{
-			IP:   net.ParseIP(ip),
+			IP:   destinationIP,
 			Port: port,
 		},
 	}
@@ -171,12 +182,16 @@ func performProxyProtocolRequestWithSourceIP(ip string, port int, endpoint strin
 		return err
 	}
 
-	// Send HTTP Request
+	// Send HTTP Request, IPv6 addresses are bracketed in the Host header
+	host := ip
+	if strings.Contains(ip, ":") {
+		host = fmt.Sprintf("[%s]", ip)
+	}
 	request := fmt.Sprintf("GET %s HTTP/1.1\r\n"+
 		"Host: %s\r\n"+
 		"Content-Length: 0\r\n"+
 		"Content-Type: text/plain\r\n"+
-		"\r\n", endpoint, ip)
+		"\r\n", endpoint, host)
 	_, err = conn.Write([]byte(request))
 	if err != nil {
 		return err
@@ -0,0 +1,101 @@
+package acceptance_tests
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Concurrency Limits", func() {
+	opsfileQueueing := `---
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/backend_max_connections?
+  value: 1
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/backend_max_queue?
+  value: 2
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/backend_queue_retry_after?
+  value: 7
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/stats_enable?
+  value: true
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/stats_promex_enable?
+  value: true
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/trusted_stats_cidrs?
+  value: 127.0.0.1/32
+`
+	It("Queues requests to a slow backend and sheds them once the queue is full", func() {
+		haproxyBackendPort := 12000
+		haproxyInfo, _ := deployHAProxy(baseManifestVars{
+			haproxyBackendPort:    haproxyBackendPort,
+			haproxyBackendServers: []string{"127.0.0.1"},
+			deploymentName:        deploymentNameForTestNode(),
+		}, []string{opsfileQueueing}, map[string]interface{}{}, true)
+
+		release := make(chan struct{})
+		closeLocalServer, localPort, err := startLocalHTTPServer(nil, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/slow" {
+				<-release
+			}
+			fmt.Fprintln(w, "Hello cloud foundry")
+		})
+		Expect(err).NotTo(HaveOccurred())
+		defer closeLocalServer()
+
+		closeTunnel := setupTunnelFromHaproxyToTestServer(haproxyInfo, haproxyBackendPort, localPort)
+		defer closeTunnel()
+
+		client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+		expectTestServer200(client.Get(fmt.Sprintf("http://%s/", haproxyInfo.PublicIP)))
+
+		By("Sending one request that occupies the only connection and two that wait in the queue")
+		var wg sync.WaitGroup
+		statusCodes := make(chan int, 3)
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				resp, err := client.Get(fmt.Sprintf("http://%s/slow", haproxyInfo.PublicIP))
+				Expect(err).NotTo(HaveOccurred())
+				resp.Body.Close()
+				statusCodes <- resp.StatusCode
+			}()
+			// Keeps the order of the requests, so that the first one occupies the connection
+			time.Sleep(200 * time.Millisecond)
+		}
+
+		By("Expecting the queue depth in the prometheus metrics")
+		Eventually(func() string {
+			stdout, _, err := runOnRemote(haproxyInfo.SSHUser, haproxyInfo.PublicIP, haproxyInfo.SSHPrivateKey, "curl -s http://127.0.0.1:9000/metrics")
+			Expect(err).NotTo(HaveOccurred())
+			return stdout
+		}, 10*time.Second, time.Second).Should(MatchRegexp(`haproxy_backend_current_queue\{proxy="http-routers-http1"\} 2`))
+
+		By("Expecting further requests to be shed with 503 and Retry-After")
+		resp, err := client.Get(fmt.Sprintf("http://%s/slow", haproxyInfo.PublicIP))
+		Expect(err).NotTo(HaveOccurred())
+		body, err := io.ReadAll(resp.Body)
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusServiceUnavailable))
+		Expect(resp.Header.Get("Retry-After")).To(Equal("7"))
+		Expect(string(body)).To(Equal("Service overloaded"))
+
+		By("Releasing the slow requests, expecting the queued ones to be served")
+		close(release)
+		wg.Wait()
+		close(statusCodes)
+		for statusCode := range statusCodes {
+			Expect(statusCode).To(Equal(http.StatusOK))
+		}
+	})
+})
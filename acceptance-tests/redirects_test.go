@@ -0,0 +1,124 @@
+package acceptance_tests
+
+import (
+	"fmt"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Redirects and rewrites", func() {
+	opsfileRedirects := `---
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/redirects?
+  value:
+  - host: old.example.com
+    target: https://new.example.com
+  - path: /pricing
+    target: https://www.example.com/plans
+    code: 302
+    preserve_query: false
+  - path: /old/caf%C3%A9
+    target: /new/cafe
+    code: 307
+  - prefix: /docs/v1/
+    target: /docs/v2/
+    code: 308
+  - regex: ^/blog/([0-9]+)/([a-z-]+)$
+    target: /articles/\2-\1
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/rewrites?
+  value:
+  - prefix: /api/v1/
+    target: /v1/
+  - regex: ^/static/[0-9a-f]+/(.*)$
+    target: /static/\1
+`
+	var closeLocalServer func()
+	var closeTunnel func()
+	var baseURL string
+	var backendPaths chan string
+	client := &http.Client{
+		Transport: &http.Transport{DisableKeepAlives: true},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	BeforeEach(func() {
+		haproxyBackendPort := 12000
+		haproxyInfo, _ := deployHAProxy(baseManifestVars{
+			haproxyBackendPort:    haproxyBackendPort,
+			haproxyBackendServers: []string{"127.0.0.1"},
+			deploymentName:        deploymentNameForTestNode(),
+		}, []string{opsfileRedirects}, map[string]interface{}{}, true)
+		baseURL = fmt.Sprintf("http://%s", haproxyInfo.PublicIP)
+
+		By("Starting a local http server to act as a backend")
+		var localPort int
+		var err error
+		backendPaths = make(chan string, 10)
+		closeLocalServer, localPort, err = startLocalHTTPServer(nil, func(w http.ResponseWriter, r *http.Request) {
+			backendPaths <- r.URL.EscapedPath()
+			_, _ = w.Write([]byte("OK"))
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		closeTunnel = setupTunnelFromHaproxyToTestServer(haproxyInfo, haproxyBackendPort, localPort)
+	})
+
+	AfterEach(func() {
+		closeLocalServer()
+		closeTunnel()
+	})
+
+	sendRequest := func(host, path string) *http.Response {
+		request, err := http.NewRequest(http.MethodGet, baseURL+path, nil)
+		Expect(err).NotTo(HaveOccurred())
+		if host != "" {
+			request.Host = host
+		}
+		resp, err := client.Do(request)
+		Expect(err).NotTo(HaveOccurred())
+		return resp
+	}
+
+	It("Redirects with the configured code and location", func() {
+		for _, tc := range []struct {
+			host     string
+			path     string
+			code     int
+			location string
+		}{
+			{"old.example.com", "/some/page?a=1", http.StatusMovedPermanently, "https://new.example.com/some/page?a=1"},
+			{"", "/pricing?ref=ad", http.StatusFound, "https://www.example.com/plans"},
+			{"", "/old/caf%C3%A9?x=y", http.StatusTemporaryRedirect, "/new/cafe?x=y"},
+			{"", "/docs/v1/guide/intro", http.StatusPermanentRedirect, "/docs/v2/guide/intro"},
+			{"", "/blog/2024/hello-world", http.StatusMovedPermanently, "/articles/hello-world-2024"},
+		} {
+			By(fmt.Sprintf("Requesting %s%s", tc.host, tc.path))
+			resp := sendRequest(tc.host, tc.path)
+			Expect(resp.StatusCode).To(Equal(tc.code))
+			Expect(resp.Header.Get("Location")).To(Equal(tc.location))
+		}
+		Consistently(backendPaths).ShouldNot(Receive())
+	})
+
+	It("Rewrites paths before sending requests to the backend", func() {
+		By("Rewriting a path prefix")
+		resp := sendRequest("", "/api/v1/users/j%C3%B6rg")
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Eventually(backendPaths).Should(Receive(Equal("/v1/users/j%C3%B6rg")))
+
+		By("Rewriting a path with a regex")
+		resp = sendRequest("", "/static/0a1b2c/app.js")
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Eventually(backendPaths).Should(Receive(Equal("/static/app.js")))
+
+		By("Leaving other paths untouched")
+		resp = sendRequest("", "/other")
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Eventually(backendPaths).Should(Receive(Equal("/other")))
+	})
+})
@@ -52,13 +52,23 @@ func copyFileToRemote(user string, addr string, privateKey string, remotePath st
 // Opens a local port forwarding SSH connection. Equivalent to
 // ssh -i <privateKey> -L <localIP>:<localPort>:<remoteIP>:<remotePort> <user>@<addr>
 func startSSHPortAndIPForwarder(user string, addr string, privateKey string, localIP string, localPort int, remoteIP string, remotePort int, ctx context.Context) error {
+	return startSSHForwarder(user, addr, privateKey, net.JoinHostPort(localIP, strconv.Itoa(localPort)), "tcp", net.JoinHostPort(remoteIP, strconv.Itoa(remotePort)), ctx)
+}
+
+// Opens a local port forwarding SSH connection to a UNIX socket. Equivalent to
+// ssh -i <privateKey> -L 127.0.0.1:<localPort>:<remoteSocketPath> <user>@<addr>
+func startSSHPortToUnixSocketForwarder(user string, addr string, privateKey string, localPort int, remoteSocketPath string, ctx context.Context) error {
+	return startSSHForwarder(user, addr, privateKey, net.JoinHostPort("127.0.0.1", strconv.Itoa(localPort)), "unix", remoteSocketPath, ctx)
+}
+
+func startSSHForwarder(user string, addr string, privateKey string, localAddr string, remoteNetwork string, remoteAddr string, ctx context.Context) error {
 	remoteConn, err := buildSSHClient(user, addr, privateKey)
 	if err != nil {
 		return err
 	}
 
-	writeLog(fmt.Sprintf("Listening on %s:%d on local machine\n", localIP, localPort))
-	localListener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", localIP, localPort))
+	writeLog(fmt.Sprintf("Listening on %s on local machine\n", localAddr))
+	localListener, err := net.Listen("tcp", localAddr)
 	if err != nil {
 		return err
 	}
@@ -76,9 +86,9 @@ func startSSHPortAndIPForwarder(user string, addr string, privateKey string, loc
 				return
 			}
 
-			remoteConn, err := remoteConn.Dial("tcp", fmt.Sprintf("%s:%d", remoteIP, remotePort))
+			remoteConn, err := remoteConn.Dial(remoteNetwork, remoteAddr)
 			if err != nil {
-				writeLog(fmt.Sprintf("Error dialing remote ip %s port %d: %s\n", remoteIP, remotePort, err.Error()))
+				writeLog(fmt.Sprintf("Error dialing remote %s address %s: %s\n", remoteNetwork, remoteAddr, err.Error()))
 				return
 			}
 
@@ -111,7 +121,7 @@ func startReverseSSHPortAndIPForwarder(user string, addr string, privateKey stri
 	}
 
 	writeLog(fmt.Sprintf("Listening on %s:%d on remote machine %s\n", remoteIP, remotePort, addr))
-	remoteListener, err := remoteConn.Listen("tcp", fmt.Sprintf("%s:%d", remoteIP, remotePort))
+	remoteListener, err := remoteConn.Listen("tcp", net.JoinHostPort(remoteIP, strconv.Itoa(remotePort)))
 	if err != nil {
 		return err
 	}
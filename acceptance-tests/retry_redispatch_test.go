@@ -2,9 +2,14 @@ package acceptance_tests
 
 import (
 	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
-	"net/http"
 )
 
 var _ = Describe("Retry and Redispatch Tests", func() {
@@ -93,3 +98,239 @@ var _ = Describe("Retry and Redispatch Tests", func() {
 		})
 	})
 })
+
+var _ = Describe("Outlier Detection", func() {
+	haproxyBackendPort := 12000
+	haproxyBackendHealthPort := 8080
+	opsfileOutlierDetection := `---
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/backend_use_http_health?
+  value: true
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/outlier_detection?
+  value:
+    enabled: true
+    consecutive_errors: 3
+    ejection_time: 60s
+    max_ejected_percent: 50
+`
+	It("Ejects servers which pass health checks but fail live traffic", func() {
+		haproxyInfo, _ := deployHAProxy(baseManifestVars{
+			haproxyBackendPort:    haproxyBackendPort,
+			haproxyBackendServers: []string{"127.0.0.1", "127.0.0.2"},
+			deploymentName:        deploymentNameForTestNode(),
+		}, []string{opsfileOutlierDetection}, map[string]interface{}{}, true)
+
+		// The servers answer every fourth request, all others fail with 502
+		var flaky [2]atomic.Bool
+		var requests [2]atomic.Int32
+		for i, ip := range []string{"127.0.0.1", "127.0.0.2"} {
+			closeHealthServer, healthPort := startDefaultTestServer(withIP(ip))
+			defer closeHealthServer()
+			closeHealthTunnel := setupTunnelFromHaproxyIPToTestServerIP(haproxyInfo, ip, haproxyBackendHealthPort, ip, healthPort)
+			defer closeHealthTunnel()
+
+			closeServer, port := startDefaultTestServer(withIP(ip), withHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if flaky[i].Load() && requests[i].Add(1)%4 != 0 {
+					w.WriteHeader(http.StatusBadGateway)
+					return
+				}
+				_, _ = w.Write([]byte("OK"))
+			}))
+			defer closeServer()
+			closeTunnel := setupTunnelFromHaproxyIPToTestServerIP(haproxyInfo, ip, haproxyBackendPort, ip, port)
+			defer closeTunnel()
+		}
+
+		client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+		sendRequests := func() {
+			for i := 0; i < 20; i++ {
+				resp, err := client.Get(fmt.Sprintf("http://%s", haproxyInfo.PublicIP))
+				Expect(err).NotTo(HaveOccurred())
+				resp.Body.Close()
+			}
+		}
+		nodeStatus := func(node string) func() string {
+			return func() string {
+				sendRequests()
+				return haproxyServerStatus(haproxyInfo, "http-routers-http1", node)
+			}
+		}
+
+		By("Waiting for both servers to pass their health checks")
+		Eventually(nodeStatus("node0"), 30*time.Second, time.Second).Should(Equal("UP"))
+		Eventually(nodeStatus("node1"), 30*time.Second, time.Second).Should(Equal("UP"))
+
+		By("Making the second server fail most requests, expecting it to be ejected")
+		flaky[1].Store(true)
+		Eventually(nodeStatus("node1"), 30*time.Second, time.Second).Should(HavePrefix("DOWN"))
+
+		By("Expecting all requests to be answered by the first server while the second is ejected")
+		for i := 0; i < 10; i++ {
+			resp, err := client.Get(fmt.Sprintf("http://%s", haproxyInfo.PublicIP))
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		}
+
+		By("Making both servers fail most requests, expecting at most half of them to be ejected")
+		flaky[0].Store(true)
+		Consistently(func() int {
+			up := 0
+			for _, node := range []string{"node0", "node1"} {
+				if nodeStatus(node)() == "UP" {
+					up++
+				}
+			}
+			return up
+		}, 20*time.Second, 2*time.Second).Should(BeNumerically(">=", 1))
+	})
+})
+
+var _ = Describe("Retry Policies", func() {
+	haproxyBackendPort := 12000
+	opsfileRetryPolicy := `---
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/retries?
+  value: 2
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/server_timeout?
+  value: 1
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/retry_policy?
+  value:
+    retry_on: [conn-failure, empty-response, response-timeout, 503]
+    budget: ((retry_budget))
+`
+	opsfileRedispatch := `---
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/enable_redispatch?
+  value: true
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/backend_use_http_health?
+  value: true
+`
+
+	// startFailingServer starts a backend which fails the first attempt of each request path, as given by its
+	// first segment, and answers the retries. It returns the number of attempts per path.
+	startFailingServer := func(haproxyInfo haproxyInfo) (func(string) int, func()) {
+		var mutex sync.Mutex
+		attempts := map[string]int{}
+		closeLocalServer, localPort, err := startLocalHTTPServer(nil, func(w http.ResponseWriter, r *http.Request) {
+			mutex.Lock()
+			attempts[r.URL.Path]++
+			first := attempts[r.URL.Path] == 1
+			mutex.Unlock()
+
+			if first {
+				switch strings.Split(r.URL.Path, "/")[1] {
+				case "empty-response":
+					conn, _, err := w.(http.Hijacker).Hijack()
+					if err == nil {
+						conn.Close()
+					}
+					return
+				case "response-timeout":
+					time.Sleep(3 * time.Second)
+				case "503":
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+			}
+			fmt.Fprintln(w, "Hello cloud foundry")
+		})
+		Expect(err).NotTo(HaveOccurred())
+		closeTunnel := setupTunnelFromHaproxyToTestServer(haproxyInfo, haproxyBackendPort, localPort)
+
+		return func(path string) int {
+				mutex.Lock()
+				defer mutex.Unlock()
+				return attempts[path]
+			}, func() {
+				closeTunnel()
+				closeLocalServer()
+			}
+	}
+
+	It("Retries requests on each of the configured conditions", func() {
+		haproxyInfo, _ := deployHAProxy(baseManifestVars{
+			haproxyBackendPort:    haproxyBackendPort,
+			haproxyBackendServers: []string{"127.0.0.1"},
+			deploymentName:        deploymentNameForTestNode(),
+		}, []string{opsfileRetryPolicy}, map[string]interface{}{
+			"retry_budget": nil,
+		}, true)
+
+		attempts, closeServer := startFailingServer(haproxyInfo)
+		defer closeServer()
+
+		client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+		for _, condition := range []string{"empty-response", "response-timeout", "503"} {
+			By(fmt.Sprintf("Retrying a GET request on %s", condition))
+			path := fmt.Sprintf("/%s/get", condition)
+			expectTestServer200(client.Get(fmt.Sprintf("http://%s%s", haproxyInfo.PublicIP, path)))
+			Expect(attempts(path)).To(Equal(2))
+		}
+
+		By("Not retrying a POST request, which is not idempotent")
+		resp, err := client.Post(fmt.Sprintf("http://%s/503/post", haproxyInfo.PublicIP), "text/plain", strings.NewReader("body"))
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusServiceUnavailable))
+		Expect(attempts("/503/post")).To(Equal(1))
+	})
+
+	It("Retries connection failures on another server", func() {
+		haproxyInfo, _ := deployHAProxy(baseManifestVars{
+			haproxyBackendPort:    haproxyBackendPort,
+			haproxyBackendServers: []string{"127.0.0.1", "127.0.0.2"},
+			deploymentName:        deploymentNameForTestNode(),
+		}, []string{opsfileRetryPolicy, opsfileRedispatch}, map[string]interface{}{
+			"retry_budget": nil,
+		}, true)
+
+		attempts, closeServer := startFailingServer(haproxyInfo)
+		defer closeServer()
+
+		// Both servers pass their health checks, but 127.0.0.2 does not listen on the traffic port
+		for _, ip := range []string{"127.0.0.1", "127.0.0.2"} {
+			closeHealthServer, healthPort := startDefaultTestServer(withIP(ip))
+			defer closeHealthServer()
+			closeHealthTunnel := setupTunnelFromHaproxyIPToTestServerIP(haproxyInfo, ip, 8080, ip, healthPort)
+			defer closeHealthTunnel()
+		}
+
+		By("Expecting all requests to be answered by the first server")
+		client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+		for i := 0; i < 10; i++ {
+			path := fmt.Sprintf("/conn-failure/%d", i)
+			expectTestServer200(client.Get(fmt.Sprintf("http://%s%s", haproxyInfo.PublicIP, path)))
+			Expect(attempts(path)).To(Equal(1))
+		}
+	})
+
+	It("Stops retrying once the retry budget is used up", func() {
+		haproxyInfo, _ := deployHAProxy(baseManifestVars{
+			haproxyBackendPort:    haproxyBackendPort,
+			haproxyBackendServers: []string{"127.0.0.1"},
+			deploymentName:        deploymentNameForTestNode(),
+		}, []string{opsfileRetryPolicy}, map[string]interface{}{
+			"retry_budget": map[string]interface{}{"percent": 0, "min_retries": 1, "window": "1m"},
+		}, true)
+
+		attempts, closeServer := startFailingServer(haproxyInfo)
+		defer closeServer()
+
+		client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+		By("Retrying the first failed request")
+		expectTestServer200(client.Get(fmt.Sprintf("http://%s/503/first", haproxyInfo.PublicIP)))
+		Expect(attempts("/503/first")).To(Equal(2))
+
+		By("Not retrying further failed requests within the window")
+		resp, err := client.Get(fmt.Sprintf("http://%s/503/second", haproxyInfo.PublicIP))
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusServiceUnavailable))
+		Expect(attempts("/503/second")).To(Equal(1))
+	})
+})
@@ -0,0 +1,67 @@
+package acceptance_tests
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Draining Backend Servers", func() {
+	opsfileSlowstart := `---
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/backend_slowstart?
+  value: 10
+`
+	It("Drains a server with haproxy-ctl and ramps it up again", func() {
+		haproxyBackendPort := 12000
+		haproxyInfo, _ := deployHAProxy(baseManifestVars{
+			haproxyBackendPort:    haproxyBackendPort,
+			haproxyBackendServers: []string{"127.0.0.1", "127.0.0.2"},
+			deploymentName:        deploymentNameForTestNode(),
+		}, []string{opsfileSlowstart}, map[string]interface{}{}, true)
+
+		for _, ip := range []string{"127.0.0.1", "127.0.0.2"} {
+			closeLocalServer, localPort := startDefaultTestServer(withIP(ip), withHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(ip))
+			}))
+			defer closeLocalServer()
+			closeTunnel := setupTunnelFromHaproxyIPToTestServerIP(haproxyInfo, ip, haproxyBackendPort, ip, localPort)
+			defer closeTunnel()
+		}
+
+		client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+		respondingServers := func() map[string]int {
+			servers := map[string]int{}
+			for i := 0; i < 10; i++ {
+				resp, err := client.Get(fmt.Sprintf("http://%s", haproxyInfo.PublicIP))
+				Expect(err).NotTo(HaveOccurred())
+				body, err := io.ReadAll(resp.Body)
+				Expect(err).NotTo(HaveOccurred())
+				resp.Body.Close()
+				servers[string(body)]++
+			}
+			return servers
+		}
+		Eventually(respondingServers, 30*time.Second, time.Second).Should(HaveKey("127.0.0.2"))
+
+		By("Draining the second server")
+		_, _, err := runOnRemote(haproxyInfo.SSHUser, haproxyInfo.PublicIP, haproxyInfo.SSHPrivateKey,
+			"sudo haproxy-ctl -ramp-down 4s -ramp-down-steps 2 drain http-routers-http1/node1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(haproxyServerStatus(haproxyInfo, "http-routers-http1", "node1")).To(Equal("MAINT"))
+		Expect(respondingServers()).To(Equal(map[string]int{"127.0.0.1": 10}))
+
+		By("Returning the second server into rotation")
+		_, _, err = runOnRemote(haproxyInfo.SSHUser, haproxyInfo.PublicIP, haproxyInfo.SSHPrivateKey,
+			"sudo haproxy-ctl ready http-routers-http1/node1")
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(func() string {
+			return haproxyServerStatus(haproxyInfo, "http-routers-http1", "node1")
+		}, 10*time.Second, time.Second).Should(Equal("UP"))
+		Eventually(respondingServers, 30*time.Second, time.Second).Should(HaveKey("127.0.0.2"))
+	})
+})
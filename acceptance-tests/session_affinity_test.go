@@ -0,0 +1,154 @@
+package acceptance_tests
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Session Affinity", func() {
+	var haproxyInfo haproxyInfo
+	var closeTunnel map[string]func()
+	var closeLocalServer map[string]func()
+	var affinityMode string
+
+	haproxyBackendPort := 12000
+	opsfileSessionAffinity := `---
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/backend_session_affinity?
+  value:
+    mode: ((session_affinity_mode))
+    failover: redispatch
+`
+
+	JustBeforeEach(func() {
+		haproxyInfo, _ = deployHAProxy(baseManifestVars{
+			haproxyBackendPort:    haproxyBackendPort,
+			haproxyBackendServers: []string{"127.0.0.1", "127.0.0.2"},
+			deploymentName:        deploymentNameForTestNode(),
+		}, []string{opsfileSessionAffinity}, map[string]interface{}{
+			"session_affinity_mode": affinityMode,
+		}, true)
+
+		closeTunnel = map[string]func(){}
+		closeLocalServer = map[string]func(){}
+		for _, backendIP := range []string{"127.0.0.1", "127.0.0.2"} {
+			closeLocalServerFunc, localPort := startNamedTestServer(backendIP)
+			closeLocalServer[backendIP] = closeLocalServerFunc
+			closeTunnel[backendIP] = setupTunnelFromHaproxyIPToTestServerIP(haproxyInfo, backendIP, haproxyBackendPort, "127.0.0.1", localPort)
+		}
+	})
+
+	AfterEach(func() {
+		for _, closeLocalServerFunc := range closeLocalServer {
+			closeLocalServerFunc()
+		}
+		for _, closeTunnelFunc := range closeTunnel {
+			closeTunnelFunc()
+		}
+	})
+
+	expectPinnedAndFailover := func(client *http.Client) {
+		url := fmt.Sprintf("http://%s", haproxyInfo.PublicIP)
+
+		By("Sending a first request to learn which server the client is pinned to")
+		pinnedServer, err := getServerName(client, url)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pinnedServer).To(BeElementOf("127.0.0.1", "127.0.0.2"))
+
+		By("Sending further requests, expecting all of them to reach the pinned server")
+		for i := 0; i < 10; i++ {
+			Expect(getServerName(client, url)).To(Equal(pinnedServer))
+		}
+
+		By("Closing the pinned server, expecting requests to move to the remaining server")
+		closeTunnel[pinnedServer]()
+		closeLocalServer[pinnedServer]()
+		delete(closeTunnel, pinnedServer)
+		delete(closeLocalServer, pinnedServer)
+
+		Eventually(func() (string, error) {
+			return getServerName(client, url)
+		}, 30*time.Second, time.Second).ShouldNot(Equal(pinnedServer))
+
+		By("Sending further requests, expecting all of them to stay on the new server")
+		newServer, err := getServerName(client, url)
+		Expect(err).NotTo(HaveOccurred())
+		Consistently(func() (string, error) {
+			return getServerName(client, url)
+		}, 5*time.Second, 500*time.Millisecond).Should(Equal(newServer))
+	}
+
+	Context("When the mode is cookie", func() {
+		BeforeEach(func() {
+			affinityMode = "cookie"
+		})
+
+		It("Pins clients to a server using an inserted cookie", func() {
+			jar, err := cookiejar.New(nil)
+			Expect(err).NotTo(HaveOccurred())
+			client := &http.Client{Jar: jar, Transport: &http.Transport{DisableKeepAlives: true}}
+
+			expectPinnedAndFailover(client)
+
+			By("Checking that the persistence cookie was set")
+			haproxyURL, err := url.Parse(fmt.Sprintf("http://%s", haproxyInfo.PublicIP))
+			Expect(err).NotTo(HaveOccurred())
+			var cookieNames []string
+			for _, cookie := range jar.Cookies(haproxyURL) {
+				cookieNames = append(cookieNames, cookie.Name)
+			}
+			Expect(cookieNames).To(ContainElement("SERVERID"))
+		})
+	})
+
+	Context("When the mode is source", func() {
+		BeforeEach(func() {
+			affinityMode = "source"
+		})
+
+		It("Pins clients to a server based on their source address", func() {
+			client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+			expectPinnedAndFailover(client)
+		})
+	})
+})
+
+// startNamedTestServer starts a local HTTP server which responds with the given name,
+// so that tests can tell which of several backend servers handled a request.
+func startNamedTestServer(name string) (func(), int) {
+	By(fmt.Sprintf("Starting a local http server named %s to act as a backend", name))
+	closeServer, port, err := startLocalHTTPServer(nil, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(name))
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	return closeServer, port
+}
+
+// getServerName returns the name of the server started with startNamedTestServer that handled the request
+func getServerName(client *http.Client, url string) (string, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("expected status code 200, got %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	return string(body), nil
+}
@@ -0,0 +1,184 @@
+package acceptance_tests
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TCP Health Checks", func() {
+	opsfileTCPHealthChecks := `---
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/tcp?
+  value:
+  - name: redis
+    port: 13000
+    backend_port: 13001
+    backend_servers: [127.0.0.1]
+    health_check:
+      type: redis
+      fall: 1
+      rise: 1
+  - name: custom
+    port: 13002
+    backend_port: 13003
+    backend_servers: [127.0.0.1]
+    health_check:
+      type: tcp-check
+      steps:
+      - send: "STATUS\n"
+      - expect_regex: "^READY"
+      fall: 1
+      rise: 1
+  - name: tls
+    port: 13004
+    backend_port: 13005
+    backend_servers: [127.0.0.1]
+    health_check:
+      type: tls
+      fall: 1
+      rise: 1
+`
+	It("Marks servers down when the protocol fails while the port still accepts connections", func() {
+		haproxyInfo, _ := deployHAProxy(baseManifestVars{
+			haproxyBackendPort:    12000,
+			haproxyBackendServers: []string{"127.0.0.1"},
+			deploymentName:        deploymentNameForTestNode(),
+		}, []string{opsfileTCPHealthChecks}, map[string]interface{}{}, true)
+
+		var redisHealthy, customHealthy, tlsHealthy atomic.Bool
+		redisHealthy.Store(true)
+		customHealthy.Store(true)
+		tlsHealthy.Store(true)
+
+		By("Starting a Redis stand-in which answers PING with LOADING when unhealthy")
+		closeRedis, redisPort := startTCPStandInServer(func(conn net.Conn) {
+			line, err := bufio.NewReader(conn).ReadString('\n')
+			if err != nil || !strings.HasPrefix(strings.ToUpper(line), "PING") {
+				return
+			}
+			if redisHealthy.Load() {
+				_, _ = conn.Write([]byte("+PONG\r\n"))
+			} else {
+				_, _ = conn.Write([]byte("-LOADING Redis is loading the dataset in memory\r\n"))
+			}
+		})
+		defer closeRedis()
+
+		By("Starting a line based stand-in which answers STATUS with READY or BUSY")
+		closeCustom, customPort := startTCPStandInServer(func(conn net.Conn) {
+			line, err := bufio.NewReader(conn).ReadString('\n')
+			if err != nil || line != "STATUS\n" {
+				return
+			}
+			if customHealthy.Load() {
+				_, _ = conn.Write([]byte("READY\n"))
+			} else {
+				_, _ = conn.Write([]byte("BUSY\n"))
+			}
+		})
+		defer closeCustom()
+
+		By("Starting a TLS stand-in which sends garbage instead of a handshake when unhealthy")
+		tlsConfig := &tls.Config{Certificates: []tls.Certificate{generateSelfSignedCertificate()}}
+		closeTLS, tlsPort := startTCPStandInServer(func(conn net.Conn) {
+			if tlsHealthy.Load() {
+				_ = tls.Server(conn, tlsConfig).Handshake()
+			} else {
+				_, _ = conn.Write([]byte("not TLS\r\n"))
+			}
+		})
+		defer closeTLS()
+
+		for backendPort, localPort := range map[int]int{13001: redisPort, 13003: customPort, 13005: tlsPort} {
+			closeTunnel := setupTunnelFromHaproxyToTestServer(haproxyInfo, backendPort, localPort)
+			defer closeTunnel()
+		}
+
+		serverStatus := func(backend string) func() string {
+			return func() string {
+				for _, line := range strings.Split(runHAProxySocketCommand(haproxyInfo, "show stat"), "\n") {
+					fields := strings.Split(line, ",")
+					if len(fields) > 17 && fields[0] == backend && fields[1] == "node0" {
+						return fields[17]
+					}
+				}
+				return ""
+			}
+		}
+
+		for _, tc := range []struct {
+			backend string
+			healthy *atomic.Bool
+		}{
+			{"tcp-redis", &redisHealthy},
+			{"tcp-custom", &customHealthy},
+			{"tcp-tls", &tlsHealthy},
+		} {
+			By(fmt.Sprintf("Expecting %s to be up while the protocol works", tc.backend))
+			Eventually(serverStatus(tc.backend), 30*time.Second, time.Second).Should(Equal("UP"))
+
+			By(fmt.Sprintf("Expecting %s to go down once the protocol fails", tc.backend))
+			tc.healthy.Store(false)
+			Eventually(serverStatus(tc.backend), 30*time.Second, time.Second).Should(Equal("DOWN"))
+
+			By(fmt.Sprintf("Expecting %s to come back up once the protocol works again", tc.backend))
+			tc.healthy.Store(true)
+			Eventually(serverStatus(tc.backend), 30*time.Second, time.Second).Should(Equal("UP"))
+		}
+	})
+})
+
+// startTCPStandInServer accepts connections on a local port and hands each of them to the handler, closing it afterwards.
+func startTCPStandInServer(handler func(net.Conn)) (func(), int) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).NotTo(HaveOccurred())
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+				handler(conn)
+			}()
+		}
+	}()
+
+	return func() { _ = listener.Close() }, listener.Addr().(*net.TCPAddr).Port
+}
+
+func generateSelfSignedCertificate() tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).NotTo(HaveOccurred())
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
@@ -0,0 +1,82 @@
+package acceptance_tests
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+/*
+Test strategy:
+  - A named HTTP frontend only listens on http.sock, a TCP frontend only listens on tcp.sock
+  - The TCP backend is http.sock, so requests to tcp.sock pass through both sockets
+  - Both sockets are reached through SSH, like a co-located job on the HAProxy VM would
+*/
+var _ = Describe("UNIX Sockets", func() {
+	socketDir := "/var/vcap/sys/run/haproxy-sidecar"
+	opsfileUnixSockets := fmt.Sprintf(`---
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/frontends?
+  value:
+  - name: sidecar
+    unix_sockets:
+    - path: %[1]s/http.sock
+      mode: "666"
+    headers:
+      X-Frontend: sidecar
+- type: replace
+  path: /instance_groups/name=haproxy/jobs/name=haproxy/properties/ha_proxy/tcp?
+  value:
+  - name: sidecar
+    unix_sockets:
+    - path: %[1]s/tcp.sock
+      mode: "666"
+    backend_servers:
+    - %[1]s/http.sock
+`, socketDir)
+
+	It("Accepts connections on UNIX sockets and connects to UNIX socket backends", func() {
+		haproxyBackendPort := 12000
+		haproxyInfo, _ := deployHAProxy(baseManifestVars{
+			haproxyBackendPort:    haproxyBackendPort,
+			haproxyBackendServers: []string{"127.0.0.1"},
+			deploymentName:        deploymentNameForTestNode(),
+		}, []string{opsfileUnixSockets}, map[string]interface{}{}, true)
+
+		closeLocalServer, localPort := startDefaultTestServer(withHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(r.Header.Get("X-Frontend")))
+		}))
+		defer closeLocalServer()
+
+		closeBackendTunnel := setupTunnelFromHaproxyToTestServer(haproxyInfo, haproxyBackendPort, localPort)
+		defer closeBackendTunnel()
+
+		By("Allowing the SSH user to reach the sockets, co-located jobs run as vcap like HAProxy")
+		_, _, err := runOnRemote(haproxyInfo.SSHUser, haproxyInfo.PublicIP, haproxyInfo.SSHPrivateKey, fmt.Sprintf("sudo chmod 755 %s", socketDir))
+		Expect(err).NotTo(HaveOccurred())
+
+		closeHTTPSocketTunnel := setupTunnelFromLocalMachineToHAProxySocket(haproxyInfo, 11000, fmt.Sprintf("%s/http.sock", socketDir))
+		defer closeHTTPSocketTunnel()
+		closeTCPSocketTunnel := setupTunnelFromLocalMachineToHAProxySocket(haproxyInfo, 11001, fmt.Sprintf("%s/tcp.sock", socketDir))
+		defer closeTCPSocketTunnel()
+
+		expectSidecarFrontend := func(url string) {
+			resp, err := http.Get(url)
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			body, err := io.ReadAll(resp.Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(body)).To(Equal("sidecar"))
+		}
+
+		By("Sending a request to the HTTP frontend socket")
+		expectSidecarFrontend("http://127.0.0.1:11000")
+
+		By("Sending a request to the TCP frontend socket, which is proxied to the HTTP frontend socket")
+		expectSidecarFrontend("http://127.0.0.1:11001")
+	})
+})
@@ -111,4 +111,100 @@ describe 'config/bpm.yml' do
       EXPECTED
     end
   end
+
+  context 'when ha_proxy.runtime_api.enabled is true' do
+    it 'runs the haproxy-ctl API as a second process' do
+      bpm_yaml = YAML.safe_load(template.render({
+        'ha_proxy' => {
+          'runtime_api' => {
+            'enabled' => true,
+            'port' => 9091,
+            'username' => 'admin',
+            'password' => 'secret'
+          }
+        }
+      }))
+
+      expect(bpm_yaml['processes'].map { |process| process['name'] }).to eq(%w[haproxy haproxy-ctl])
+      expect(bpm_yaml['processes'][1]).to include({
+        'executable' => '/var/vcap/packages/haproxy-ctl/bin/haproxy-ctl',
+        'args' => ['-listen', '127.0.0.1:9091', 'serve'],
+        'env' => { 'HAPROXY_CTL_USERNAME' => 'admin', 'HAPROXY_CTL_PASSWORD' => 'secret' }
+      })
+      expect(bpm_yaml['processes'][1]['unsafe']).not_to include('privileged')
+    end
+
+    it 'requires credentials' do
+      expect do
+        template.render({ 'ha_proxy' => { 'runtime_api' => { 'enabled' => true } } })
+      end.to raise_error(Bosh::Template::UnknownProperty)
+    end
+  end
+
+  context 'when outlier detection limits the ejected servers' do
+    it 'runs the haproxy-ctl outlier guard as a second process' do
+      bpm_yaml = YAML.safe_load(template.render({
+        'ha_proxy' => {
+          'outlier_detection' => { 'enabled' => true, 'max_ejected_percent' => 50 },
+          'routed_backend_servers' => {
+            '/api' => { 'servers' => ['10.0.0.2'], 'port' => 8080, 'outlier_detection' => { 'max_ejected_percent' => 25 } },
+            '/images' => { 'servers' => ['10.0.0.3'], 'port' => 8080, 'outlier_detection' => {} }
+          }
+        }
+      }))
+
+      expect(bpm_yaml['processes'].map { |process| process['name'] }).to eq(%w[haproxy outlier-guard])
+      expect(bpm_yaml['processes'][1]).to include({
+        'executable' => '/var/vcap/packages/haproxy-ctl/bin/haproxy-ctl',
+        'args' => ['outlier-guard', 'http-routers-http1=50', 'http-routers-http2=50', 'http-routed-backend-702acf=25']
+      })
+    end
+  end
+
+  context 'when servers are discovered from files' do
+    it 'runs haproxy-ctl file-discovery as a second process' do
+      bpm_yaml = YAML.safe_load(template.render({
+        'ha_proxy' => {
+          'backend_discovery_file' => '/var/vcap/data/haproxy-discovery/routers.json',
+          'routed_backend_servers' => {
+            '/images' => { 'port' => 8080, 'discovery_file' => '/var/vcap/data/images/endpoints.yml' }
+          }
+        }
+      }))
+
+      expect(bpm_yaml['processes'].map { |process| process['name'] }).to eq(%w[haproxy file-discovery])
+      expect(bpm_yaml['processes'][1]).to include({
+        'executable' => '/var/vcap/packages/haproxy-ctl/bin/haproxy-ctl',
+        'args' => ['-interval', '5s', 'file-discovery']
+      })
+      expect(bpm_yaml['processes'][1]['additional_volumes']).to eq([
+        { 'path' => '/var/vcap/sys/run/haproxy', 'writable' => true },
+        { 'path' => '/var/vcap/data/haproxy-discovery' },
+        { 'path' => '/var/vcap/data/images' }
+      ])
+      expect(bpm_yaml['processes'][1]).not_to have_key('unsafe')
+    end
+  end
+
+  context 'when UNIX sockets are bound or connected to' do
+    it 'grants BPM access to the socket directories' do
+      bpm_yaml = YAML.safe_load(template.render({
+        'ha_proxy' => {
+          'frontends' => [{ 'name' => 'sidecar', 'unix_sockets' => [{ 'path' => '/var/vcap/sys/run/haproxy-sidecar/http.sock', 'mode' => '660' }, 'abns@sidecar'] }],
+          'tcp' => [{
+            'name' => 'redis',
+            'unix_sockets' => ['/var/vcap/sys/run/haproxy/redis.sock'],
+            'backend_servers' => ['/var/vcap/sys/run/redis/redis.sock']
+          }],
+          'backend_servers' => ['unix@/var/vcap/sys/run/envoy/ingress.sock', '10.0.0.1']
+        }
+      }))
+
+      expect(bpm_yaml['processes'][0]['unsafe']['unrestricted_volumes']).to eq([
+        { 'path' => '/var/vcap/sys/run/haproxy-sidecar', 'writable' => true },
+        { 'path' => '/var/vcap/sys/run/envoy' },
+        { 'path' => '/var/vcap/sys/run/redis' }
+      ])
+    end
+  end
 end
@@ -479,6 +479,30 @@ describe 'config/certs.ttar' do
     end
   end
 
+  describe 'ha_proxy.crt_list[].alpn with ha_proxy.enable_http3' do
+    let(:ttar) do
+      template.render({
+        'ha_proxy' => {
+          'enable_http3' => true,
+          'crt_list' => [{
+            'alpn' => ['h2', 'http/1.1'],
+            'verify' => 'none',
+            'ssl_pem' => 'ssl_pem contents'
+          }]
+        }
+      })
+    end
+
+    it 'is included in the crt list' do
+      expect(ttar_entry(ttar, '/var/vcap/jobs/haproxy/config/ssl/crt-list')).to include('/var/vcap/jobs/haproxy/config/ssl/cert-0.pem [verify none alpn h2,http/1.1]')
+    end
+
+    it 'is left out of the quic crt list' do
+      expect(ttar_entry(ttar, '/var/vcap/jobs/haproxy/config/ssl/crt-list-quic')).to include('/var/vcap/jobs/haproxy/config/ssl/cert-0.pem [verify none]')
+      expect(ttar_entry(ttar, '/var/vcap/jobs/haproxy/config/ssl/crt-list-quic')).not_to include('alpn')
+    end
+  end
+
   describe 'ha_proxy.ext_crt_list' do
     context 'when there are no internal certificates' do
       let(:ttar) do
@@ -541,6 +565,38 @@ describe 'config/certs.ttar' do
     end
   end
 
+  describe 'ha_proxy.frontends[].ssl_pem' do
+    let(:ttar) do
+      template.render({
+        'ha_proxy' => {
+          'frontends' => [{
+            'name' => 'public',
+            'port' => 8080
+          }, {
+            'name' => 'internal',
+            'port' => 8443,
+            'ssl_pem' => [{
+              'cert_chain' => 'cert_chain 0 contents',
+              'private_key' => 'private_key 0 contents'
+            }, 'cert 1 contents'],
+            'client_cert' => true,
+            'client_ca_file' => 'client ca contents'
+          }]
+        }
+      })
+    end
+
+    it 'writes the certificates of each frontend' do
+      expect(ttar_entry(ttar, '/var/vcap/jobs/haproxy/config/frontends/internal-cert-0.pem').strip).to eq("cert_chain 0 contents\nprivate_key 0 contents")
+      expect(ttar_entry(ttar, '/var/vcap/jobs/haproxy/config/frontends/internal-cert-1.pem').strip).to eq('cert 1 contents')
+      expect(ttar_entry(ttar, '/var/vcap/jobs/haproxy/config/frontends/internal-client-ca.pem').strip).to eq('client ca contents')
+    end
+
+    it 'does not write certificates for frontends without TLS' do
+      expect(ttar).not_to include('/var/vcap/jobs/haproxy/config/frontends/public')
+    end
+  end
+
   context 'when no certificate-related properties are provided' do
     it 'is empty' do
       expect(template.render({})).to be_a_blank_string
@@ -0,0 +1,218 @@
+# frozen_string_literal: true
+
+require 'rspec'
+
+describe 'config/haproxy.config abuse protection' do
+  let(:haproxy_conf) do
+    parse_haproxy_config(template.render({ 'ha_proxy' => properties }))
+  end
+
+  let(:frontend_http) { haproxy_conf['frontend http-in'] }
+  let(:frontend_https) { haproxy_conf['frontend https-in'] }
+  let(:backend_abuse_src) { haproxy_conf['backend st_abuse_src'] }
+  let(:backend_abuse_path) { haproxy_conf['backend st_abuse_path'] }
+
+  let(:default_properties) do
+    {
+      'ssl_pem' => 'ssl pem contents', # required for https-in frontend
+      'abuse_protection' => {
+        'window_size' => '10s',
+        'table_size' => '100k',
+        'error_rate' => 20,
+        'auth_failure_rate' => 5,
+        'paths' => [
+          { 'name' => 'login', 'prefix' => '/login', 'requests' => 10 },
+          { 'name' => 'oauth_token', 'prefix' => '/oauth/token', 'requests' => 30 }
+        ]
+      }
+    }
+  end
+
+  let(:properties) { default_properties }
+
+  context 'when ha_proxy.abuse_protection "window_size" and "table_size" are NOT provided' do
+    let(:properties) { { 'abuse_protection' => { 'error_rate' => 20 } } }
+
+    it 'does not track abuse' do
+      expect(haproxy_conf).not_to have_key('backend st_abuse_src')
+      expect(haproxy_conf['global']).not_to include(match(/abuse_protection/))
+      expect(frontend_http).not_to include(match(/abuse/))
+    end
+  end
+
+  it 'sets up stick-tables' do
+    expect(backend_abuse_src).to include('stick-table type ipv6 size 100k expire 10s store http_err_rate(10s),gpc(1),gpc_rate(1,10s)')
+    expect(backend_abuse_path).to include('stick-table type string len 64 size 100k expire 10s store http_req_rate(10s)')
+  end
+
+  it 'initialises the thresholds as proc variables' do
+    expect(haproxy_conf['global']).to include('tune.stick-counters 5')
+    expect(haproxy_conf['global']).to include('set-var proc.abuse_protection_block bool(false)')
+    expect(haproxy_conf['global']).to include('set-var proc.abuse_protection_error_rate int(20)')
+    expect(haproxy_conf['global']).to include('set-var proc.abuse_protection_auth_failure_rate int(5)')
+    expect(haproxy_conf['global']).to include('set-var proc.abuse_protection_login_requests int(10)')
+    expect(haproxy_conf['global']).to include('set-var proc.abuse_protection_oauth_token_requests int(30)')
+  end
+
+  it 'tracks error and request rates in http-in and https-in frontends' do
+    [frontend_http, frontend_https].each do |frontend|
+      expect(frontend).to include('http-request track-sc3 src table st_abuse_src')
+      expect(frontend).to include('http-response sc-inc-gpc(0,3) if { status 401 }')
+      expect(frontend).to include('http-request set-var(txn.abuse_reason) str(error_rate) if { var(proc.abuse_protection_error_rate) -m int gt 0 } { sc_http_err_rate(3),sub(proc.abuse_protection_error_rate) gt 0 }')
+      expect(frontend).to include('http-request set-var(txn.abuse_reason) str(auth_failure_rate) if { var(proc.abuse_protection_auth_failure_rate) -m int gt 0 } { sc_gpc_rate(0,3),sub(proc.abuse_protection_auth_failure_rate) gt 0 }')
+      expect(frontend).to include('http-request set-var(txn.abuse_path) str(login) if { path_beg /login } !{ var(txn.abuse_path) -m found }')
+      expect(frontend).to include('http-request set-var(txn.abuse_path) str(oauth_token) if { path_beg /oauth/token } !{ var(txn.abuse_path) -m found }')
+      expect(frontend).to include('http-request track-sc4 src,concat(_,txn.abuse_path) table st_abuse_path if { var(txn.abuse_path) -m found }')
+      expect(frontend).to include('http-request set-var(txn.abuse_reason) str(login_request_rate) if { var(txn.abuse_path) -m str login } { var(proc.abuse_protection_login_requests) -m int gt 0 } { sc_http_req_rate(4),sub(proc.abuse_protection_login_requests) gt 0 }')
+    end
+  end
+
+  it 'tarpits abusive sources by default' do
+    expect(frontend_http).to include('acl abusive var(txn.abuse_reason) -m found')
+    expect(frontend_http).to include('acl abuse_block var(proc.abuse_protection_block) -m bool')
+    expect(frontend_http).to include('http-request set-var-fmt(txn.block_reason) "blocked: abuse protection %[var(txn.abuse_reason)]" if abusive abuse_block')
+    expect(frontend_http).to include('timeout tarpit 10s')
+    expect(frontend_http).to include('http-request tarpit deny_status 429 if abusive abuse_block')
+  end
+
+  context 'when no paths are provided' do
+    let(:properties) do
+      default_properties.merge({ 'abuse_protection' => default_properties['abuse_protection'].merge({ 'paths' => [] }) })
+    end
+
+    it 'does not track requests per path' do
+      expect(haproxy_conf).not_to have_key('backend st_abuse_path')
+      expect(frontend_http).not_to include(match(/track-sc4/))
+    end
+  end
+
+  context 'when block is true and action is deny' do
+    let(:properties) do
+      default_properties.deep_merge({ 'abuse_protection' => { 'block' => true, 'action' => 'deny', 'deny_status' => 403 } })
+    end
+
+    it 'denies abusive sources' do
+      expect(haproxy_conf['global']).to include('set-var proc.abuse_protection_block bool(true)')
+      expect(frontend_http).to include('http-request deny deny_status 403 if abusive abuse_block')
+      expect(frontend_http).not_to include(match(/tarpit/))
+    end
+  end
+
+  context 'when action is challenge' do
+    let(:properties) do
+      default_properties.deep_merge({
+        'custom_http_error_files' => { '429' => 'challenge page' },
+        'abuse_protection' => { 'action' => 'challenge', 'challenge_secret' => 's3cr3t' }
+      })
+    end
+
+    it 'serves the challenge page unless the challenge cookie is valid' do
+      expect(frontend_http).to include('acl abuse_challenge_passed var(txn.abuse_challenge_passed) -m bool')
+      expect(frontend_http).to include('http-request deny deny_status 429 errorfile /var/vcap/jobs/haproxy/errorfiles/custom429.http if abusive abuse_block !abuse_challenge_passed')
+    end
+
+    it 'only accepts cookies signed for the source IP within the max age' do
+      expect(frontend_http).to include('http-request set-var(txn.abuse_challenge_time) req.cook(haproxy_challenge),field(1,.)')
+      expect(frontend_http).to include('http-request set-var(txn.abuse_challenge_signature) req.cook(haproxy_challenge),field(2,.)')
+      expect(frontend_http).to include('acl abuse_challenge_signed src,concat(_,txn.abuse_challenge_time),hmac(sha256,czNjcjN0),hex,secure_memcmp(txn.abuse_challenge_signature) -m bool')
+      expect(frontend_http).to include('acl abuse_challenge_fresh date,sub(txn.abuse_challenge_time) -m int 0:3600')
+      expect(frontend_http).to include('http-request set-var(txn.abuse_challenge_passed) bool(true) if abusive abuse_block abuse_challenge_fresh abuse_challenge_signed')
+    end
+
+    it 'issues a signed token with the challenge page' do
+      expect(frontend_http).to include('http-request set-var(txn.abuse_challenge_issued) date if abusive abuse_block !abuse_challenge_passed')
+      expect(frontend_http).to include('http-after-response add-header Set-Cookie "haproxy_challenge_token=%[var(txn.abuse_challenge_issued)].%[src,concat(_,txn.abuse_challenge_issued),hmac(sha256,czNjcjN0),hex]; Path=/; Max-Age=3600; SameSite=Strict" if { var(txn.abuse_challenge_issued) -m found }')
+    end
+
+    context 'when there is no challenge page' do
+      let(:properties) do
+        default_properties.deep_merge({
+          'custom_http_error_files' => { '503' => 'error page' },
+          'abuse_protection' => { 'action' => 'challenge', 'challenge_secret' => 's3cr3t' }
+        })
+      end
+
+      it 'aborts with a meaningful error message' do
+        expect { frontend_http }.to raise_error(/requires a challenge page in custom_http_error_files for status 429/)
+      end
+    end
+
+    context 'when there is no challenge secret' do
+      let(:properties) do
+        default_properties.deep_merge({
+          'custom_http_error_files' => { '429' => 'challenge page' },
+          'abuse_protection' => { 'action' => 'challenge' }
+        })
+      end
+
+      it 'aborts with a meaningful error message' do
+        expect { frontend_http }.to raise_error(/abuse_protection.challenge_secret must be set/)
+      end
+    end
+
+    context 'when the challenge max age is not positive' do
+      let(:properties) do
+        default_properties.deep_merge({
+          'custom_http_error_files' => { '429' => 'challenge page' },
+          'abuse_protection' => { 'action' => 'challenge', 'challenge_secret' => 's3cr3t', 'challenge_max_age' => 0 }
+        })
+      end
+
+      it 'aborts with a meaningful error message' do
+        expect { frontend_http }.to raise_error(/abuse_protection.challenge_max_age must be a positive number of seconds/)
+      end
+    end
+  end
+
+  context 'when action is unknown' do
+    let(:properties) do
+      default_properties.deep_merge({ 'abuse_protection' => { 'action' => 'drop' } })
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect { frontend_http }.to raise_error(/Unknown 'abuse_protection.action' option: drop/)
+    end
+  end
+
+  context 'when a path name is invalid' do
+    let(:properties) do
+      default_properties.merge({
+        'abuse_protection' => default_properties['abuse_protection'].merge({
+          'paths' => [{ 'name' => 'log in', 'prefix' => '/login', 'requests' => 10 }]
+        })
+      })
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect { frontend_http }.to raise_error(/each abuse_protection.paths entry needs a prefix and a name/)
+    end
+  end
+
+  context 'when a path request limit is not a positive integer' do
+    let(:properties) do
+      default_properties.merge({
+        'abuse_protection' => default_properties['abuse_protection'].merge({
+          'paths' => [{ 'name' => 'login', 'prefix' => '/login', 'requests' => '10/s' }]
+        })
+      })
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect { frontend_http }.to raise_error(/abuse_protection.paths.login.requests must be a positive integer/)
+    end
+  end
+
+  context 'when a path request limit is missing' do
+    let(:properties) do
+      default_properties.merge({
+        'abuse_protection' => default_properties['abuse_protection'].merge({
+          'paths' => [{ 'name' => 'login', 'prefix' => '/login' }]
+        })
+      })
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect { frontend_http }.to raise_error(/abuse_protection.paths.login.requests must be a positive integer/)
+    end
+  end
+end
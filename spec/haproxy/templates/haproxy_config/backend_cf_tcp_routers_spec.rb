@@ -42,6 +42,19 @@ describe 'config/haproxy.config backend cf_tcp_routers' do
     expect(backend_cf_tcp_routers).to include('server node0 tcp.cf.com check port 80 inter 1000')
   end
 
+  context 'when a tcp router has an IPv6 address' do
+    let(:tcp_router_link) do
+      Bosh::Template::Test::Link.new(
+        name: 'tcp_router',
+        instances: [Bosh::Template::Test::LinkInstance.new(address: 'fd00::5')]
+      )
+    end
+
+    it 'keeps the destination port' do
+      expect(backend_cf_tcp_routers).to include('server node0 fd00::5: check port 80 inter 1000')
+    end
+  end
+
   context 'when no tcp_router link is provided' do
     let(:haproxy_conf) do
       parse_haproxy_config(template.render(properties))
@@ -0,0 +1,200 @@
+# frozen_string_literal: true
+
+require 'rspec'
+
+describe 'config/haproxy.config host_routes' do
+  let(:haproxy_conf) do
+    parse_haproxy_config(template.render({ 'ha_proxy' => properties }))
+  end
+
+  let(:default_properties) do
+    {
+      'host_routes' => {
+        'app.example.com' => {
+          'servers' => ['10.0.0.2', '10.0.0.3'],
+          'port' => '8080'
+        },
+        '*.example.com' => {
+          'servers' => ['10.0.0.8'],
+          'port' => '443'
+        }
+      }
+    }
+  end
+
+  let(:properties) { default_properties }
+
+  let(:frontend_http) { haproxy_conf['frontend http-in'] }
+  let(:backend_app) { haproxy_conf['backend http-host-backend-app.example.com'] }
+  let(:backend_wildcard) { haproxy_conf['backend http-host-backend-.example.com'] }
+
+  it 'looks up the backend for the request host in the map files' do
+    expect(frontend_http).to include('http-request set-var(txn.host_route_backend) req.hdr(host),host_only,lower,map(/var/vcap/jobs/haproxy/config/host_routes.map)')
+    expect(frontend_http).to include('http-request set-var(txn.host_route_backend) req.hdr(host),host_only,lower,map_end(/var/vcap/jobs/haproxy/config/host_routes_wildcard.map) unless { var(txn.host_route_backend) -m found }')
+    expect(frontend_http).to include('use_backend http-host-backend-%[var(txn.host_route_backend)] if { var(txn.host_route_backend) -m found }')
+  end
+
+  it 'does not route plain HTTP requests by SNI' do
+    expect(frontend_http).not_to include(match(/ssl_fc_sni/))
+  end
+
+  context 'when TLS is enabled' do
+    let(:properties) do
+      default_properties.merge({ 'ssl_pem' => 'ssl pem contents' })
+    end
+
+    it 'routes requests by SNI if there is no Host header' do
+      frontend_https = haproxy_conf['frontend https-in']
+      expect(frontend_https).to include('http-request set-var(txn.host_route_backend) req.hdr(host),host_only,lower,map(/var/vcap/jobs/haproxy/config/host_routes.map)')
+      expect(frontend_https).to include('http-request set-var(txn.host_route_backend) ssl_fc_sni,lower,map(/var/vcap/jobs/haproxy/config/host_routes.map) unless { req.hdr(host) -m found }')
+      expect(frontend_https).to include('http-request set-var(txn.host_route_backend) ssl_fc_sni,lower,map_end(/var/vcap/jobs/haproxy/config/host_routes_wildcard.map) unless { req.hdr(host) -m found } || { var(txn.host_route_backend) -m found }')
+      expect(frontend_https).to include('use_backend http-host-backend-%[var(txn.host_route_backend)] if { var(txn.host_route_backend) -m found }')
+    end
+
+    it 'does not route requests by SNI if their Host header matches no route' do
+      frontend_https = haproxy_conf['frontend https-in']
+      expect(frontend_https.select { |line| line.include?('ssl_fc_sni,lower,map') }).to all(include('unless { req.hdr(host) -m found }'))
+    end
+  end
+
+  it 'has a backend per host' do
+    expect(backend_app).to include('mode http')
+    expect(backend_app).to include('balance roundrobin')
+    expect(backend_app).to include('server node0 10.0.0.2:8080 check inter 1000')
+    expect(backend_app).to include('server node1 10.0.0.3:8080 check inter 1000')
+    expect(backend_wildcard).to include('server node0 10.0.0.8:443 check inter 1000')
+  end
+
+  context 'when ha_proxy.host_routes is not provided' do
+    let(:properties) { {} }
+
+    it 'does not look up host routes' do
+      expect(frontend_http).not_to include(match(/host_route_backend/))
+      expect(haproxy_conf.keys).not_to include(match(/^backend http-host-backend-/))
+    end
+  end
+
+  context 'when backend_ssl is verify with backend_verifyhost' do
+    let(:properties) do
+      default_properties.deep_merge({
+        'host_routes' => {
+          '*.example.com' => {
+            'backend_ssl' => 'verify',
+            'backend_verifyhost' => 'example.com'
+          }
+        }
+      })
+    end
+
+    it 'configures ssl on the backend servers' do
+      expect(backend_wildcard).to include('server node0 10.0.0.8:443 check inter 1000 ssl verify required ca-file /var/vcap/jobs/haproxy/config/backend-ca-certs.pem verifyhost example.com')
+    end
+  end
+
+  context 'when backend_verifyhost is provided without backend_ssl verify' do
+    let(:properties) do
+      default_properties.deep_merge({
+        'host_routes' => {
+          '*.example.com' => {
+            'backend_ssl' => 'noverify',
+            'backend_verifyhost' => 'example.com'
+          }
+        }
+      })
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect { backend_wildcard }.to raise_error(/backend_ssl must be 'verify' to use backend_verifyhost in host_routes/)
+    end
+  end
+
+  context 'when backend_use_http_health is true' do
+    let(:properties) do
+      default_properties.deep_merge({
+        'host_routes' => {
+          'app.example.com' => {
+            'backend_use_http_health' => true,
+            'backend_http_health_port' => 8081,
+            'backend_health_fall' => 5,
+            'backend_health_rise' => 1
+          }
+        }
+      })
+    end
+
+    it 'configures http health checks' do
+      expect(backend_app).to include('option httpchk GET /health')
+      expect(backend_app).to include('server node0 10.0.0.2:8080 check inter 1000 port 8081 fall 5 rise 1')
+      expect(backend_wildcard).to include('server node0 10.0.0.8:443 check inter 1000')
+    end
+  end
+
+  context 'when a host specific compression is configured' do
+    let(:properties) do
+      default_properties.deep_merge({
+        'host_routes' => {
+          'app.example.com' => {
+            'compression' => {
+              'algorithms' => ['deflate'],
+              'exclude_paths' => ['/login']
+            }
+          }
+        },
+        'compress_types' => 'text/html'
+      })
+    end
+
+    it 'configures the compression of the host' do
+      expect(backend_app).to include('compression algo deflate')
+      expect(backend_app).to include('compression type text/html')
+      expect(backend_app).to include('http-request del-header Accept-Encoding if { path_beg /login }')
+      expect(backend_wildcard).to include('compression algo gzip')
+      expect(backend_wildcard).not_to include(/Accept-Encoding/)
+    end
+  end
+
+  context 'when a wildcard is not the leftmost label' do
+    let(:properties) do
+      { 'host_routes' => { 'app.*.example.com' => { 'servers' => ['10.0.0.2'], 'port' => '80' } } }
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect { frontend_http }.to raise_error(/Invalid host 'app.\*.example.com' in host_routes/)
+    end
+  end
+
+  context 'when a host contains characters which are not valid in a backend name' do
+    let(:properties) do
+      { 'host_routes' => { 'app.example.com:8080' => { 'servers' => ['10.0.0.2'], 'port' => '80' } } }
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect { frontend_http }.to raise_error(/Invalid host 'app.example.com:8080' in host_routes/)
+    end
+  end
+
+  context 'when hosts only differ in case' do
+    let(:properties) do
+      {
+        'host_routes' => {
+          'app.example.com' => { 'servers' => ['10.0.0.2'], 'port' => '80' },
+          'App.Example.com' => { 'servers' => ['10.0.0.3'], 'port' => '80' }
+        }
+      }
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect { frontend_http }.to raise_error(/host_routes.app.example.com and host_routes.App.Example.com only differ in case/)
+    end
+  end
+
+  context 'when servers are missing' do
+    let(:properties) do
+      { 'host_routes' => { 'app.example.com' => { 'port' => '80' } } }
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect { frontend_http }.to raise_error(/host_routes.app.example.com must provide servers and port/)
+    end
+  end
+end
@@ -324,6 +324,338 @@ describe 'config/haproxy.config backend http-routed-backend-X' do
     end
   end
 
+  context 'when a route specific compression is configured' do
+    let(:properties) do
+      default_properties.deep_merge({
+        'routed_backend_servers' => {
+          '/images' => {
+            'compression' => {
+              'algorithms' => %w[gzip deflate],
+              'types' => ['image/svg+xml'],
+              'min_size' => 1024,
+              'offload' => true,
+              'exclude_paths' => ['/images/private']
+            }
+          }
+        },
+        'compress_types' => 'text/html'
+      })
+    end
+
+    it 'configures the compression of the route' do
+      expect(backend_images).to include('compression algo gzip deflate')
+      expect(backend_images).to include('compression type image/svg+xml')
+      expect(backend_images).to include('compression minsize-res 1024')
+      expect(backend_images).to include('compression offload')
+      expect(backend_images).to include('http-request del-header Accept-Encoding if { path_beg /images/private }')
+      expect(backend_images).not_to include('compression type text/html')
+    end
+
+    it 'uses the global compression for other routes' do
+      expect(backend_auth).to include('compression algo gzip')
+      expect(backend_auth).to include('compression type text/html')
+    end
+
+    context 'when types are not provided' do
+      let(:properties) do
+        default_properties.deep_merge({
+          'routed_backend_servers' => {
+            '/images' => {
+              'compression' => {}
+            }
+          },
+          'compress_types' => 'text/html text/css'
+        })
+      end
+
+      it 'uses the global compress_types' do
+        expect(backend_images).to include('compression algo gzip')
+        expect(backend_images).to include('compression type text/html text/css')
+      end
+    end
+
+    context 'when compression is disabled for the route' do
+      let(:properties) do
+        default_properties.deep_merge({
+          'routed_backend_servers' => {
+            '/images' => {
+              'compression' => { 'enabled' => false }
+            }
+          },
+          'compress_types' => 'text/html'
+        })
+      end
+
+      it 'does not compress responses of the route' do
+        expect(backend_images).not_to include(/compression/)
+        expect(backend_auth).to include('compression algo gzip')
+      end
+    end
+
+    context 'when request compression is configured' do
+      let(:properties) do
+        default_properties.deep_merge({
+          'routed_backend_servers' => {
+            '/images' => {
+              'compression' => {
+                'request' => {
+                  'algorithm' => 'deflate',
+                  'types' => ['application/json'],
+                  'min_size' => 512
+                }
+              }
+            }
+          }
+        })
+      end
+
+      it 'compresses request bodies' do
+        expect(backend_images).to include('compression direction both')
+        expect(backend_images).to include('compression algo-req deflate')
+        expect(backend_images).to include('compression type-req application/json')
+        expect(backend_images).to include('compression minsize-req 512')
+      end
+    end
+
+    context 'when an unknown algorithm is provided' do
+      let(:properties) do
+        default_properties.deep_merge({
+          'routed_backend_servers' => {
+            '/images' => {
+              'compression' => { 'algorithms' => ['br'] }
+            }
+          }
+        })
+      end
+
+      it 'aborts with a meaningful error message' do
+        expect do
+          backend_images
+        end.to raise_error(%r{Unknown 'routed_backend_servers./images.compression.algorithms' option: br. Known options: 'gzip', 'deflate', 'raw-deflate'})
+      end
+    end
+  end
+
+  context 'when a cache is configured' do
+    let(:properties) do
+      default_properties.deep_merge({
+        'routed_backend_servers' => {
+          '/images' => {
+            'cache' => {
+              'total_max_size' => 128,
+              'max_object_size' => 1_048_576,
+              'max_age' => 300,
+              'process_vary' => true
+            }
+          }
+        }
+      })
+    end
+
+    it 'declares the cache' do
+      expect(haproxy_conf['cache routed-cache-9c1bb7']).to contain_exactly(
+        'total-max-size 128',
+        'max-object-size 1048576',
+        'max-age 300',
+        'process-vary on'
+      )
+    end
+
+    it 'uses and stores responses in the cache' do
+      expect(backend_images).to include('http-request cache-use routed-cache-9c1bb7')
+      expect(backend_images).to include('http-response cache-store routed-cache-9c1bb7')
+      expect(backend_images).to include('http-after-response set-header X-Cache %[res.cache_hit,iif(HIT,MISS)]')
+      expect(backend_auth).not_to include(/cache/)
+      expect(haproxy_conf).not_to have_key('cache routed-cache-7d2f30')
+    end
+
+    context 'when only defaults are used' do
+      let(:properties) do
+        default_properties.deep_merge({
+          'routed_backend_servers' => {
+            '/images' => {
+              'cache' => {}
+            }
+          }
+        })
+      end
+
+      it 'declares the cache with defaults' do
+        expect(haproxy_conf['cache routed-cache-9c1bb7']).to contain_exactly(
+          'total-max-size 64',
+          'max-age 60',
+          'process-vary off'
+        )
+      end
+    end
+
+    context 'when ha_proxy.compress_types are provided' do
+      let(:properties) do
+        default_properties.deep_merge({
+          'routed_backend_servers' => {
+            '/images' => {
+              'cache' => {}
+            }
+          },
+          'compress_types' => 'text/html'
+        })
+      end
+
+      it 'declares the compression and cache filters' do
+        expect(backend_images).to include('filter compression')
+        expect(backend_images).to include('filter cache routed-cache-9c1bb7')
+        expect(backend_images.index('filter compression')).to be < backend_images.index('filter cache routed-cache-9c1bb7')
+        expect(backend_auth).not_to include('filter compression')
+      end
+    end
+  end
+
+  context 'when backend_protocol is grpc' do
+    let(:properties) do
+      default_properties.deep_merge({
+        'routed_backend_servers' => {
+          '/images' => {
+            'backend_protocol' => 'grpc'
+          }
+        },
+        'compress_types' => 'text/html'
+      })
+    end
+
+    it 'connects to the servers using h2c' do
+      expect(backend_images).to include('server node0 10.0.0.2:443 check inter 1000 proto h2')
+      expect(backend_images).to include('server node1 10.0.0.3:443 check inter 1000 proto h2')
+      expect(backend_auth).to include('server node0 10.0.0.8:8080 check inter 1000')
+    end
+
+    it 'captures the grpc-status header' do
+      expect(backend_images).to include('http-response capture res.hdr(grpc-status) id 0')
+      expect(backend_auth).not_to include('http-response capture res.hdr(grpc-status) id 0')
+      expect(haproxy_conf['frontend http-in']).to include('declare capture response len 4')
+    end
+
+    it 'does not compress responses' do
+      expect(backend_images).not_to include('compression algo gzip')
+      expect(backend_auth).to include('compression algo gzip')
+    end
+
+    context 'when backend_ssl is verify' do
+      let(:properties) do
+        default_properties.deep_merge({
+          'routed_backend_servers' => {
+            '/images' => {
+              'backend_protocol' => 'grpc',
+              'backend_ssl' => 'verify'
+            }
+          }
+        })
+      end
+
+      it 'negotiates h2 via ALPN' do
+        expect(backend_images).to include('server node0 10.0.0.2:443 check inter 1000 ssl verify required ca-file /var/vcap/jobs/haproxy/config/backend-ca-certs.pem alpn h2')
+      end
+    end
+
+    context 'when backend_use_grpc_health is true' do
+      let(:properties) do
+        default_properties.deep_merge({
+          'routed_backend_servers' => {
+            '/images' => {
+              'backend_protocol' => 'grpc',
+              'backend_use_grpc_health' => true,
+              'backend_health_fall' => 3,
+              'backend_health_rise' => 2
+            }
+          }
+        })
+      end
+
+      it 'checks the servers with the grpc health checking protocol' do
+        expect(backend_images).to include('option tcp-check')
+        expect(backend_images).to include('tcp-check connect')
+        expect(backend_images).to include('tcp-check send-binary 505249202a20485454502f322e300d0a0d0a534d0d0a0d0a0000000400000000000000400104000000018386041c2f677270632e6865616c74682e76312e4865616c74682f436865636b0f10106170706c69636174696f6e2f677270630002746508747261696c6572730000050001000000010000000000')
+        expect(backend_images).to include('tcp-check expect binary 00000000020801')
+        expect(backend_images).to include('server node0 10.0.0.2:443 check inter 1000 fall 3 rise 2 proto h2')
+      end
+
+      context 'when backend_ssl is verify and backend_grpc_health_service is provided' do
+        let(:properties) do
+          default_properties.deep_merge({
+            'routed_backend_servers' => {
+              '/images' => {
+                'backend_protocol' => 'grpc',
+                'backend_ssl' => 'verify',
+                'backend_use_grpc_health' => true,
+                'backend_grpc_health_service' => 'orders'
+              }
+            }
+          })
+        end
+
+        it 'checks the service over tls' do
+          expect(backend_images).to include('tcp-check connect ssl alpn h2')
+          expect(backend_images).to include('tcp-check send-binary 505249202a20485454502f322e300d0a0d0a534d0d0a0d0a0000000400000000000000400104000000018387041c2f677270632e6865616c74682e76312e4865616c74682f436865636b0f10106170706c69636174696f6e2f677270630002746508747261696c65727300000d00010000000100000000080a066f7264657273')
+        end
+      end
+
+      context 'when backend_use_http_health is true as well' do
+        let(:properties) do
+          default_properties.deep_merge({
+            'routed_backend_servers' => {
+              '/images' => {
+                'backend_protocol' => 'grpc',
+                'backend_use_grpc_health' => true,
+                'backend_use_http_health' => true
+              }
+            }
+          })
+        end
+
+        it 'aborts with a meaningful error message' do
+          expect do
+            backend_images
+          end.to raise_error(%r{Conflicting configuration: routed_backend_servers./images can use either backend_use_http_health or backend_use_grpc_health, but not both})
+        end
+      end
+    end
+  end
+
+  context 'when backend_use_grpc_health is true and backend_protocol is not grpc' do
+    let(:properties) do
+      default_properties.deep_merge({
+        'routed_backend_servers' => {
+          '/images' => {
+            'backend_use_grpc_health' => true
+          }
+        }
+      })
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect do
+        backend_images
+      end.to raise_error(%r{Conflicting configuration: routed_backend_servers./images.backend_protocol must be 'grpc' to use backend_use_grpc_health})
+    end
+  end
+
+  context 'when backend_protocol is unknown' do
+    let(:properties) do
+      default_properties.deep_merge({
+        'routed_backend_servers' => {
+          '/images' => {
+            'backend_protocol' => 'websocket'
+          }
+        }
+      })
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect do
+        backend_images
+      end.to raise_error(%r{Unknown 'routed_backend_servers./images.backend_protocol' option: websocket. Known options: 'http', 'grpc'})
+    end
+  end
+
   context 'when ha_proxy.routed_backend_servers is not provided' do
     let(:haproxy_conf) do
       parse_haproxy_config(template.render({}))
@@ -333,4 +665,164 @@ describe 'config/haproxy.config backend http-routed-backend-X' do
       expect(haproxy_conf).not_to have_key(/backend http-routed-backend/)
     end
   end
+
+  context 'when outlier_detection is provided' do
+    let(:properties) do
+      {
+        'routed_backend_servers' => {
+          '/images' => {
+            'servers' => ['10.0.0.2'],
+            'port' => '443',
+            'outlier_detection' => { 'observe' => 'layer4', 'max_ejected_percent' => 50 }
+          }
+        }
+      }
+    end
+
+    it 'ejects servers after consecutive errors on live traffic' do
+      expect(backend_images).to include('server node0 10.0.0.2:443 check inter 1000 observe layer4 error-limit 5 on-error mark-down downinter 30s')
+    end
+
+    context 'when max_ejected_percent is out of range' do
+      let(:properties) do
+        {
+          'routed_backend_servers' => {
+            '/images' => { 'servers' => ['10.0.0.2'], 'port' => '443', 'outlier_detection' => { 'max_ejected_percent' => 150 } }
+          }
+        }
+      end
+
+      it 'aborts with a meaningful error message' do
+        expect do
+          backend_images
+        end.to raise_error(%r{Conflicting configuration: routed_backend_servers./images.outlier_detection.max_ejected_percent must be between 0 and 100})
+      end
+    end
+  end
+
+  context 'when concurrency limits are provided' do
+    let(:properties) do
+      {
+        'routed_backend_servers' => {
+          '/images' => {
+            'servers' => ['10.0.0.2'],
+            'port' => '443',
+            'max_connections' => 10,
+            'max_queue' => 20,
+            'queue_retry_after' => 0,
+            'queue_timeout' => 2.5
+          }
+        }
+      }
+    end
+
+    it 'limits the connections per server and the queue' do
+      expect(backend_images).to include('server node0 10.0.0.2:443 check inter 1000 maxconn 10')
+      expect(backend_images).to include('timeout queue 2500ms')
+      expect(backend_images).to include('http-request return status 503 content-type "text/plain" string "Service overloaded" if { queue ge 20 }')
+    end
+  end
+
+  context 'when retry_policy is provided' do
+    let(:properties) do
+      {
+        'retries' => 1,
+        'retry_policy' => { 'retry_on' => ['conn-failure'] },
+        'routed_backend_servers' => {
+          '/images' => {
+            'servers' => ['10.0.0.2'],
+            'port' => '443',
+            'retry_policy' => { 'retries' => 3, 'redispatch' => false, 'retry_on' => ['response-timeout', '504'], 'non_idempotent' => true }
+          }
+        }
+      }
+    end
+
+    it 'overrides the global retry policy' do
+      expect(backend_images).to include('retries 3')
+      expect(backend_images).to include('no option redispatch')
+      expect(backend_images).to include('retry-on response-timeout 504')
+      expect(backend_images).not_to include(/disable-l7-retry/)
+    end
+
+    context 'when retry_policy is not provided' do
+      let(:properties) do
+        {
+          'retries' => 1,
+          'retry_policy' => { 'retry_on' => ['conn-failure'] },
+          'routed_backend_servers' => { '/images' => { 'servers' => ['10.0.0.2'], 'port' => '443' } }
+        }
+      end
+
+      it 'uses the global retry policy' do
+        expect(backend_images).to include('retry-on conn-failure')
+        expect(backend_images).not_to include(/^retries/)
+      end
+    end
+  end
+
+  context 'when slowstart is provided' do
+    let(:properties) do
+      {
+        'routed_backend_servers' => {
+          '/images' => { 'servers' => ['10.0.0.2'], 'port' => '443', 'slowstart' => 30 }
+        }
+      }
+    end
+
+    it 'ramps up the traffic of recovered servers' do
+      expect(backend_images).to include('server node0 10.0.0.2:443 check inter 1000 slowstart 30000ms')
+    end
+  end
+
+  context 'when discovery is provided' do
+    let(:properties) do
+      {
+        'resolvers' => [{ 'bosh_dns' => '169.254.0.2' }],
+        'routed_backend_servers' => {
+          '/images' => { 'port' => '443', 'discovery' => { 'name' => '_images._tcp.example.com', 'slots' => 5 } }
+        }
+      }
+    end
+
+    it 'fills the servers from the records of the name' do
+      expect(backend_images).to include('server-template node 5 _images._tcp.example.com resolvers default init-addr none resolve-prefer ipv4 check inter 1000')
+    end
+  end
+
+  context 'when discovery_file is provided' do
+    let(:properties) do
+      {
+        'routed_backend_servers' => {
+          '/images' => { 'port' => '443', 'discovery_file' => '/var/vcap/data/images/endpoints.yml', 'discovery_file_slots' => 1 }
+        }
+      }
+    end
+
+    it 'starts with server slots in maintenance' do
+      expect(backend_images).to include('server node0 file-discovery:443 init-addr last,0.0.0.0 disabled check inter 1000')
+      expect(backend_images).not_to include(/server node1/)
+    end
+
+    it 'keeps the servers filled by haproxy-ctl across reloads' do
+      expect(haproxy_conf['global']).to include('server-state-file /var/vcap/sys/run/haproxy/server-state')
+      expect(backend_images).to include('load-server-state-from-file global')
+    end
+
+    context 'when the file is outside of /var/vcap/data and /var/vcap/store' do
+      let(:properties) do
+        {
+          'routed_backend_servers' => {
+            '/images' => { 'port' => '443', 'discovery_file' => '/etc/images.yml' }
+          }
+        }
+      end
+
+      it 'aborts with a meaningful error message' do
+        expect do
+          backend_images
+        end.to raise_error(%r{Conflicting configuration: routed_backend_servers./images.discovery_file must be in a directory of its own below})
+      end
+    end
+  end
 end
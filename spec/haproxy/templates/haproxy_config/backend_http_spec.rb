@@ -461,4 +461,269 @@ describe 'config/haproxy.config backend http-routers' do
       end
     end
   end
+
+  context 'when ha_proxy.outlier_detection is enabled' do
+    let(:properties) do
+      {
+        'backend_use_http_health' => true,
+        'backend_servers' => ['10.0.0.1'],
+        'outlier_detection' => { 'enabled' => true, 'consecutive_errors' => 3, 'ejection_time' => '10s' }
+      }
+    end
+
+    it 'ejects servers after consecutive errors on live traffic' do
+      expect(backend_http1).to include('server node0 10.0.0.1:80 check inter 1000 observe layer7 error-limit 3 on-error mark-down downinter 10s port 8080 fall 3 rise 2')
+    end
+
+    context 'when observe is unknown' do
+      let(:properties) do
+        { 'outlier_detection' => { 'enabled' => true, 'observe' => 'layer5' } }
+      end
+
+      it 'aborts with a meaningful error message' do
+        expect do
+          backend_http1
+        end.to raise_error(/Unknown 'outlier_detection.observe' option: layer5. Known options: 'layer4', 'layer7'/)
+      end
+    end
+  end
+
+  context 'when ha_proxy.backend_max_connections and backend_max_queue are provided' do
+    let(:properties) do
+      {
+        'backend_use_http_health' => true,
+        'backend_servers' => ['10.0.0.1'],
+        'backend_max_connections' => 50,
+        'backend_max_queue' => 100
+      }
+    end
+
+    it 'limits the connections per server' do
+      expect(backend_http1).to include('server node0 10.0.0.1:80 check inter 1000 maxconn 50 port 8080 fall 3 rise 2')
+    end
+
+    it 'answers with 503 once the queue is full' do
+      expect(backend_http1).to include('http-request return status 503 content-type "text/plain" string "Service overloaded" hdr Retry-After 5 if { queue ge 100 }')
+    end
+
+    context 'when backend_max_connections is missing' do
+      let(:properties) { { 'backend_max_queue' => 100 } }
+
+      it 'aborts with a meaningful error message' do
+        expect do
+          backend_http1
+        end.to raise_error(/Conflicting configuration: ha_proxy.backend_max_queue requires max_connections with max_queue/)
+      end
+    end
+  end
+
+  context 'when ha_proxy.retry_policy is provided' do
+    let(:properties) do
+      {
+        'retries' => 2,
+        'retry_policy' => {
+          'retry_on' => ['conn-failure', 'empty-response', '503'],
+          'budget' => { 'percent' => 10 }
+        }
+      }
+    end
+
+    it 'retries on the given conditions' do
+      expect(backend_http1).to include('retry-on conn-failure empty-response 503')
+    end
+
+    it 'does not retry non-idempotent requests which may have reached the server' do
+      expect(backend_http1).to include('http-request disable-l7-retry unless { method GET HEAD PUT DELETE OPTIONS TRACE }')
+    end
+
+    it 'limits the retries to a budget' do
+      expect(haproxy_conf['backend st_retry_budget_http-routers-http1']).to eq(['stick-table type integer size 1 expire 10s store http_req_rate(10s),gpc0_rate(10s)'])
+      expect(backend_http1).to include('http-request track-sc2 int(0) table st_retry_budget_http-routers-http1')
+      expect(backend_http1).to include('http-request set-var(txn.retry_budget) sc_http_req_rate(2),mul(10),div(100)')
+      expect(backend_http1).to include('http-request disable-l7-retry if { sc_gpc0_rate(2) ge 3 } { sc_gpc0_rate(2),sub(txn.retry_budget) ge 0 }')
+      expect(backend_http1).to include('http-response sc-inc-gpc0(2) if { retries gt 0 }')
+    end
+
+    context 'when non_idempotent is true' do
+      let(:properties) do
+        { 'retries' => 2, 'retry_policy' => { 'retry_on' => ['503'], 'non_idempotent' => true } }
+      end
+
+      it 'retries requests of all methods' do
+        expect(backend_http1).to include('retry-on 503')
+        expect(backend_http1).not_to include(/disable-l7-retry/)
+      end
+    end
+
+    context 'when only conn-failure is given' do
+      let(:properties) do
+        { 'retries' => 2, 'retry_policy' => { 'retry_on' => ['conn-failure'], 'budget' => {} } }
+      end
+
+      it 'does not need to limit the retries' do
+        expect(backend_http1).to include('retry-on conn-failure')
+        expect(backend_http1).not_to include(/disable-l7-retry|track-sc2/)
+        expect(haproxy_conf).not_to have_key('backend st_retry_budget_http-routers-http1')
+      end
+    end
+
+    context 'when retries is 0' do
+      let(:properties) { { 'retry_policy' => { 'retry_on' => ['503'] } } }
+
+      it 'aborts with a meaningful error message' do
+        expect do
+          backend_http1
+        end.to raise_error(/Conflicting configuration: ha_proxy.retry_policy.retry_on works only with retries > 0/)
+      end
+    end
+
+    context 'when a condition is unknown' do
+      let(:properties) { { 'retries' => 2, 'retry_policy' => { 'retry_on' => ['timeout'] } } }
+
+      it 'aborts with a meaningful error message' do
+        expect do
+          backend_http1
+        end.to raise_error(/Unknown 'ha_proxy.retry_policy.retry_on' option: timeout/)
+      end
+    end
+  end
+
+  context 'when ha_proxy.backend_slowstart is provided' do
+    let(:properties) do
+      { 'backend_servers' => ['10.0.0.1'], 'backend_slowstart' => 2.5 }
+    end
+
+    it 'ramps up the traffic of recovered servers' do
+      expect(backend_http1).to include('server node0 10.0.0.1:80 check inter 1000 slowstart 2500ms')
+    end
+
+    context 'when backend_slowstart is not positive' do
+      let(:properties) { { 'backend_servers' => ['10.0.0.1'], 'backend_slowstart' => 0 } }
+
+      it 'aborts with a meaningful error message' do
+        expect do
+          backend_http1
+        end.to raise_error(/Conflicting configuration: ha_proxy.backend_slowstart must be greater than 0/)
+      end
+    end
+  end
+
+  context 'when ha_proxy.backend_discovery is provided' do
+    let(:properties) do
+      {
+        'resolvers' => [{ 'bosh_dns' => '169.254.0.2' }],
+        'backend_servers' => ['10.0.0.1'],
+        'backend_discovery' => { 'name' => 'q-s0.router.default.cf.bosh', 'slots' => 20 }
+      }
+    end
+
+    it 'fills the servers from the records of the name' do
+      expect(backend_http1).to include('server-template node 20 q-s0.router.default.cf.bosh:80 resolvers default init-addr none resolve-prefer ipv4 check inter 1000')
+      expect(backend_http1).not_to include(/^server node0/)
+    end
+
+    context 'when the name is an SRV record' do
+      let(:properties) do
+        {
+          'resolvers' => [{ 'bosh_dns' => '169.254.0.2' }],
+          'backend_discovery' => { 'name' => '_http._tcp.router.example.com', 'resolve_prefer' => 'ipv6' }
+        }
+      end
+
+      it 'takes the ports from the records' do
+        expect(backend_http1).to include('server-template node 10 _http._tcp.router.example.com resolvers default init-addr none resolve-prefer ipv6 check inter 1000')
+      end
+    end
+
+    context 'when ha_proxy.resolvers is not provided' do
+      let(:properties) { { 'backend_discovery' => { 'name' => 'q-s0.router.default.cf.bosh' } } }
+
+      it 'aborts with a meaningful error message' do
+        expect do
+          backend_http1
+        end.to raise_error(/Conflicting configuration: ha_proxy.backend_discovery requires ha_proxy.resolvers/)
+      end
+    end
+
+    context 'when cookie based session affinity is enabled' do
+      let(:properties) do
+        {
+          'resolvers' => [{ 'bosh_dns' => '169.254.0.2' }],
+          'backend_discovery' => { 'name' => 'q-s0.router.default.cf.bosh' },
+          'backend_session_affinity' => { 'mode' => 'cookie' }
+        }
+      end
+
+      it 'aborts with a meaningful error message' do
+        expect do
+          backend_http1
+        end.to raise_error(/Conflicting configuration: backend_session_affinity mode 'cookie' needs listed servers/)
+      end
+    end
+  end
+
+  context 'when ha_proxy.backend_discovery_file is provided' do
+    let(:properties) do
+      {
+        'backend_servers' => ['10.0.0.1'],
+        'backend_discovery_file' => '/var/vcap/data/haproxy-discovery/routers.json',
+        'backend_discovery_file_slots' => 2
+      }
+    end
+
+    it 'starts with server slots in maintenance' do
+      expect(backend_http1).to include('server node0 file-discovery:80 init-addr last,0.0.0.0 disabled check inter 1000')
+      expect(backend_http1).to include('server node1 file-discovery:80 init-addr last,0.0.0.0 disabled check inter 1000')
+      expect(backend_http1).not_to include(/10.0.0.1/)
+    end
+
+    it 'keeps the servers filled by haproxy-ctl across reloads' do
+      expect(haproxy_conf['global']).to include('server-state-file /var/vcap/sys/run/haproxy/server-state')
+      expect(backend_http1).to include('load-server-state-from-file global')
+      expect(backend_http2).to include('load-server-state-from-file global')
+    end
+
+    it 'marks the backends for haproxy-ctl with the file and the default port' do
+      expect(template.render({ 'ha_proxy' => properties })).to include(
+        "backend http-routers-http1\n    mode http\n    balance roundrobin\n    # file-discovery /var/vcap/data/haproxy-discovery/routers.json 80\n"
+      )
+    end
+
+    context 'when the file is not in a directory of its own below /var/vcap/data or /var/vcap/store' do
+      ['/tmp/routers.json', '/var/vcap/data/routers.json', '/var/vcap/data/sys/run/routers.json',
+       '/var/vcap/data/haproxy-discovery/../jobs/routers.json', 'routers.json'].each do |path|
+        it "aborts with a meaningful error message for #{path}" do
+          expect do
+            template.render({ 'ha_proxy' => { 'backend_discovery_file' => path } })
+          end.to raise_error(/Conflicting configuration: ha_proxy.backend_discovery_file must be in a directory of its own below/)
+        end
+      end
+    end
+
+    context 'when backend_discovery is provided as well' do
+      let(:properties) do
+        {
+          'resolvers' => [{ 'bosh_dns' => '169.254.0.2' }],
+          'backend_discovery' => { 'name' => 'q-s0.router.default.cf.bosh' },
+          'backend_discovery_file' => '/var/vcap/data/haproxy-discovery/routers.json'
+        }
+      end
+
+      it 'aborts with a meaningful error message' do
+        expect do
+          backend_http1
+        end.to raise_error(/Conflicting configuration: backend_discovery and backend_discovery_file cannot be used together/)
+      end
+    end
+  end
+
+  context 'when ha_proxy.backend_servers are UNIX sockets' do
+    let(:properties) do
+      { 'backend_servers' => ['unix@/var/vcap/sys/run/envoy/ingress.sock'] }
+    end
+
+    it 'connects to the socket without a port' do
+      expect(backend_http1).to include('server node0 unix@/var/vcap/sys/run/envoy/ingress.sock check inter 1000')
+    end
+  end
 end
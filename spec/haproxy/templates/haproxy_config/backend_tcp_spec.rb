@@ -241,6 +241,119 @@ describe 'config/haproxy.config custom TCP backends' do
     end
   end
 
+  context 'when a health_check is provided' do
+    let(:properties) do
+      {
+        'tcp' => [{
+          'name' => 'redis',
+          'port' => 6379,
+          'backend_servers' => ['10.0.0.1'],
+          'health_check' => health_check
+        }]
+      }
+    end
+
+    context 'with tcp-check steps' do
+      let(:health_check) do
+        {
+          'type' => 'tcp-check',
+          'steps' => [
+            { 'send' => "PING\r\n" },
+            { 'expect' => '+PONG' },
+            { 'send_binary' => '0A0B' },
+            { 'expect_regex' => '^role:master' },
+            { 'expect_binary' => '0c' }
+          ],
+          'port' => 6380,
+          'fall' => 2,
+          'rise' => 1
+        }
+      end
+
+      it 'sends and expects the steps' do
+        expect(backend_tcp_redis).to include('option tcp-check')
+        expect(backend_tcp_redis).to include('tcp-check connect')
+        expect(backend_tcp_redis).to include('tcp-check send "PING\r\n"')
+        expect(backend_tcp_redis).to include('tcp-check expect string "+PONG"')
+        expect(backend_tcp_redis).to include('tcp-check send-binary 0a0b')
+        expect(backend_tcp_redis).to include("tcp-check expect rstring '^role:master'")
+        expect(backend_tcp_redis).to include('tcp-check expect binary 0c')
+        expect(backend_tcp_redis).to include('server node0 10.0.0.1:6379 check port 6380 inter 1000 fall 2 rise 1')
+      end
+    end
+
+    context 'with tcp-check steps over TLS' do
+      let(:health_check) do
+        { 'type' => 'tcp-check', 'ssl' => true, 'steps' => [{ 'send' => 'PING' }, { 'expect' => 'PONG' }] }
+      end
+
+      it 'connects with TLS without verifying the certificate' do
+        expect(backend_tcp_redis).to include('tcp-check connect ssl')
+        expect(backend_tcp_redis).to include('server node0 10.0.0.1:6379 check port 6379 inter 1000 verify none')
+      end
+    end
+
+    context 'with a TLS handshake check' do
+      let(:health_check) { { 'type' => 'tls', 'sni' => 'redis.example.com' } }
+
+      it 'checks the TLS handshake' do
+        expect(backend_tcp_redis).to include('server node0 10.0.0.1:6379 check port 6379 inter 1000 check-ssl verify none check-sni redis.example.com')
+      end
+    end
+
+    {
+      'redis' => 'option redis-check',
+      'ldap' => 'option ldap-check',
+      'smtp' => 'option smtp-check EHLO localhost'
+    }.each do |type, option|
+      context "with the #{type} check" do
+        let(:health_check) { { 'type' => type } }
+
+        it 'uses the protocol check' do
+          expect(backend_tcp_redis).to include(option)
+        end
+      end
+    end
+
+    context 'with the mysql check' do
+      let(:health_check) { { 'type' => 'mysql', 'user' => 'haproxy' } }
+
+      it 'logs in with the user' do
+        expect(backend_tcp_redis).to include('option mysql-check user haproxy post-41')
+      end
+    end
+
+    context 'with the pgsql check without user' do
+      let(:health_check) { { 'type' => 'pgsql' } }
+
+      it 'aborts with a meaningful error message' do
+        expect do
+          backend_tcp_redis
+        end.to raise_error(/Conflicting configuration: tcp.redis.health_check.user must be set for pgsql health checks/)
+      end
+    end
+
+    context 'with an unknown type' do
+      let(:health_check) { { 'type' => 'kafka' } }
+
+      it 'aborts with a meaningful error message' do
+        expect do
+          backend_tcp_redis
+        end.to raise_error(/Unknown 'tcp.redis.health_check.type' option: kafka/)
+      end
+    end
+
+    context 'with a step that is not hex encoded' do
+      let(:health_check) { { 'type' => 'tcp-check', 'steps' => [{ 'send_binary' => 'PING' }] } }
+
+      it 'aborts with a meaningful error message' do
+        expect do
+          backend_tcp_redis
+        end.to raise_error(/Conflicting configuration: tcp.redis.health_check.steps\[0\].send_binary must be hex encoded/)
+      end
+    end
+  end
+
   context 'when ha_proxy.tcp is not provided' do
     let(:haproxy_conf) do
       parse_haproxy_config(template.render({}))
@@ -250,4 +363,61 @@ describe 'config/haproxy.config custom TCP backends' do
       expect(haproxy_conf).not_to have_key(/backend tcp/)
     end
   end
+
+  context 'when slowstart is provided' do
+    let(:properties) do
+      {
+        'tcp_link_port' => 5432,
+        'tcp' => [{ 'name' => 'redis', 'port' => 6379, 'backend_servers' => ['10.0.0.1'], 'slowstart' => 30 }]
+      }
+    end
+
+    it 'ramps up the traffic of recovered servers' do
+      expect(backend_tcp_redis).to include('server node0 10.0.0.1:6379 check port 6379 inter 1000 slowstart 30000ms')
+    end
+  end
+
+  context 'when discovery is provided' do
+    let(:properties) do
+      {
+        'tcp_link_port' => 5432,
+        'resolvers' => [{ 'bosh_dns' => '169.254.0.2' }],
+        'tcp' => [{
+          'name' => 'redis',
+          'port' => 6379,
+          'discovery' => { 'name' => 'q-s0.redis.default.redis.bosh', 'slots' => 3 }
+        }, {
+          'name' => 'mysql',
+          'port' => 3306,
+          'discovery' => { 'name' => '_mysql._tcp.example.com' }
+        }]
+      }
+    end
+
+    it 'fills the servers from the records of the name' do
+      expect(backend_tcp_redis).to include('server-template node 3 q-s0.redis.default.redis.bosh:6379 resolvers default init-addr none resolve-prefer ipv4 check port 6379 inter 1000')
+    end
+
+    it 'checks servers discovered via SRV records on their own port' do
+      expect(backend_tcp_mysql).to include('server-template node 10 _mysql._tcp.example.com resolvers default init-addr none resolve-prefer ipv4 check inter 1000')
+    end
+  end
+
+  context 'when backend_servers are UNIX sockets' do
+    let(:properties) do
+      {
+        'tcp_link_port' => 5432,
+        'tcp' => [{
+          'name' => 'redis',
+          'unix_sockets' => ['/var/vcap/sys/run/haproxy-sidecar/redis.sock'],
+          'backend_servers' => ['/var/vcap/sys/run/redis/redis.sock', 'abns@redis']
+        }]
+      }
+    end
+
+    it 'connects to the sockets without a port' do
+      expect(backend_tcp_redis).to include('server node0 /var/vcap/sys/run/redis/redis.sock check inter 1000')
+      expect(backend_tcp_redis).to include('server node1 abns@redis check inter 1000')
+    end
+  end
 end
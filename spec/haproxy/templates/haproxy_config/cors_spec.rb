@@ -0,0 +1,117 @@
+# frozen_string_literal: true
+
+require 'rspec'
+
+describe 'config/haproxy.config CORS' do
+  let(:haproxy_conf) do
+    parse_haproxy_config(template.render({ 'ha_proxy' => properties }))
+  end
+
+  let(:frontend_http) { haproxy_conf['frontend http-in'] }
+  let(:frontend_https) { haproxy_conf['frontend https-in'] }
+
+  let(:default_properties) do
+    {
+      'ssl_pem' => 'ssl pem contents',
+      'cors' => [{
+        'hosts' => ['App.example.com'],
+        'path_prefixes' => ['/api'],
+        'allowed_origins' => ['https://app.example.com', 'https://admin.example.com'],
+        'allowed_origin_regexes' => ['^https://[a-z0-9-]+\.example\.org$'],
+        'allowed_methods' => %w[GET PUT],
+        'allowed_headers' => %w[Content-Type Authorization],
+        'exposed_headers' => ['X-Request-Id'],
+        'allow_credentials' => true,
+        'max_age' => 3600
+      }]
+    }
+  end
+
+  let(:properties) { default_properties }
+
+  it 'matches the host and path prefix of the policy' do
+    [frontend_http, frontend_https].each do |frontend|
+      expect(frontend).to include('acl cors_0_host req.hdr(host),host_only,lower -m str app.example.com')
+      expect(frontend).to include('acl cors_0_path path_beg /api')
+      expect(frontend).to include('http-request set-var(txn.cors_route) int(0) if !{ var(txn.cors_route) -m found } cors_0_host cors_0_path')
+    end
+  end
+
+  it 'accepts exact and regex origins' do
+    expect(frontend_http).to include('http-request set-var(txn.cors_origin) req.hdr(origin) if { var(txn.cors_route) -m int 0 } { req.hdr(origin) -m str https://app.example.com https://admin.example.com }')
+    expect(frontend_http).to include("http-request set-var(txn.cors_origin) req.hdr(origin) if { var(txn.cors_route) -m int 0 } { req.hdr(origin) -m reg '^https://[a-z0-9-]+\\.example\\.org$' }")
+  end
+
+  it 'answers preflight requests' do
+    expect(frontend_http).to include('http-request return status 204 hdr Access-Control-Allow-Origin "%[var(txn.cors_origin)]" hdr Access-Control-Allow-Methods "GET, PUT" hdr Access-Control-Allow-Headers "Content-Type, Authorization" hdr Access-Control-Allow-Credentials true hdr Access-Control-Max-Age 3600 hdr Vary Origin if { method OPTIONS } { req.hdr(access-control-request-method) -m found } { var(txn.cors_route) -m int 0 } { var(txn.cors_origin) -m found }')
+    expect(frontend_http).to include('http-request return status 403 if { method OPTIONS } { req.hdr(access-control-request-method) -m found } { var(txn.cors_route) -m found } !{ var(txn.cors_origin) -m found }')
+  end
+
+  it 'adds the CORS headers to responses' do
+    expect(frontend_http).to include('http-response set-header Access-Control-Allow-Origin "%[var(txn.cors_origin)]" if { var(txn.cors_origin) -m found }')
+    expect(frontend_http).to include('http-response set-header Access-Control-Allow-Credentials true if { var(txn.cors_route) -m int 0 } { var(txn.cors_origin) -m found }')
+    expect(frontend_http).to include('http-response set-header Access-Control-Expose-Headers "X-Request-Id" if { var(txn.cors_route) -m int 0 } { var(txn.cors_origin) -m found }')
+    expect(frontend_http).to include('http-response add-header Vary Origin if { var(txn.cors_route) -m found }')
+  end
+
+  context 'when a policy only provides origins' do
+    let(:properties) do
+      { 'cors' => [{ 'allowed_origins' => ['*'] }] }
+    end
+
+    it 'applies to all requests with the default methods' do
+      expect(frontend_http).not_to include(/acl cors_0_/)
+      expect(frontend_http).to include('http-request set-var(txn.cors_route) int(0) if !{ var(txn.cors_route) -m found }')
+      expect(frontend_http).to include('http-request set-var(txn.cors_origin) req.hdr(origin) if { var(txn.cors_route) -m int 0 } { req.hdr(origin) -m found }')
+      expect(frontend_http).to include('http-request return status 204 hdr Access-Control-Allow-Origin "%[var(txn.cors_origin)]" hdr Access-Control-Allow-Methods "GET, HEAD, POST" hdr Access-Control-Max-Age 600 hdr Vary Origin if { method OPTIONS } { req.hdr(access-control-request-method) -m found } { var(txn.cors_route) -m int 0 } { var(txn.cors_origin) -m found }')
+      expect(frontend_http).not_to include(/Access-Control-Allow-Credentials/)
+    end
+  end
+
+  context 'when there are multiple policies' do
+    let(:properties) do
+      { 'cors' => [
+        { 'path_prefixes' => ['/api'], 'allowed_origins' => ['https://a.example.com'] },
+        { 'allowed_origins' => ['https://b.example.com'] }
+      ] }
+    end
+
+    it 'applies the first matching policy' do
+      expect(frontend_http).to include('http-request set-var(txn.cors_route) int(0) if !{ var(txn.cors_route) -m found } cors_0_path')
+      expect(frontend_http).to include('http-request set-var(txn.cors_route) int(1) if !{ var(txn.cors_route) -m found }')
+      expect(frontend_http).to include('http-request set-var(txn.cors_origin) req.hdr(origin) if { var(txn.cors_route) -m int 1 } { req.hdr(origin) -m str https://b.example.com }')
+    end
+  end
+
+  context 'when a policy has no origins' do
+    let(:properties) do
+      { 'cors' => [{ 'hosts' => ['app.example.com'] }] }
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect do
+        frontend_http
+      end.to raise_error(/Conflicting configuration: cors\[0\] must provide allowed_origins or allowed_origin_regexes/)
+    end
+  end
+
+  context 'when credentials are allowed for all origins' do
+    let(:properties) do
+      { 'cors' => [{ 'allowed_origins' => ['*'], 'allow_credentials' => true }] }
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect do
+        frontend_http
+      end.to raise_error(/Conflicting configuration: cors\[0\] must not allow credentials for all origins/)
+    end
+  end
+
+  context 'when ha_proxy.cors is not provided' do
+    let(:properties) { {} }
+
+    it 'does not add CORS handling' do
+      expect(frontend_http).not_to include(/cors/)
+    end
+  end
+end
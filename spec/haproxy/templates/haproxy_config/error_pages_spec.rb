@@ -0,0 +1,131 @@
+# frozen_string_literal: true
+
+require 'rspec'
+
+describe 'config/haproxy.config error pages' do
+  let(:haproxy_conf) do
+    parse_haproxy_config(template.render({ 'ha_proxy' => properties }))
+  end
+
+  let(:frontend_http) { haproxy_conf['frontend http-in'] }
+  let(:frontend_https) { haproxy_conf['frontend https-in'] }
+  let(:backend_http) { haproxy_conf['backend http-routers-http1'] }
+  let(:backend_api) { haproxy_conf['backend http-routed-backend-702acf'] }
+
+  let(:default_properties) do
+    {
+      'ssl_pem' => 'ssl pem contents',
+      'backend_servers' => ['10.0.0.1'],
+      'routed_backend_servers' => {
+        '/api' => {
+          'servers' => ['10.0.0.2'],
+          'port' => '8080'
+        }
+      },
+      'error_pages' => [
+        { 'statuses' => [503], 'html' => '<h1>{{status}}</h1>', 'json' => '{"status": {{status}}}' },
+        { 'statuses' => [404], 'hosts' => ['Shop.example.com'], 'intercept_backend_errors' => true, 'html' => '<h1>Not found</h1>' },
+        { 'statuses' => [500, 503], 'routed_backends' => ['/api'], 'intercept_backend_errors' => true, 'json' => '{"error": {{status}}}' }
+      ]
+    }
+  end
+
+  let(:properties) { default_properties }
+
+  it 'keeps a request ID and the preferred content type for the pages' do
+    [frontend_http, frontend_https].each do |frontend|
+      expect(frontend).to include('unique-id-format %{+X}o%ci:%cp_%fi:%fp_%Ts_%rt:%pid')
+      expect(frontend).to include("http-request set-var(txn.request_id) req.hdr(x-request-id) if { req.hdr(x-request-id) -m reg '^[A-Za-z0-9._-]{1,128}$' }")
+      expect(frontend).to include('http-request set-var(txn.request_id) unique-id unless { var(txn.request_id) -m found }')
+      expect(frontend).to include('http-request set-var(txn.error_page_json) bool(true) if { req.hdr(accept) -m sub -i json } !{ req.hdr(accept) -m sub -i text/html }')
+    end
+  end
+
+  it 'intercepts backend errors for hosts' do
+    expect(frontend_http).to include('http-request set-var(txn.error_page_1) bool(true) if { req.hdr(host),host_only,lower -m str shop.example.com }')
+    expect(frontend_http).to include('http-response return status 404 content-type "text/html; charset=utf-8" lf-file /var/vcap/jobs/haproxy/errorpages/1-404.html if { status 404 } { var(txn.error_page_1) -m bool }')
+  end
+
+  it 'replaces errors generated by HAProxy in all backends with the HTML page' do
+    [backend_http, backend_api].each do |backend|
+      expect(backend).to include('http-error status 503 content-type "text/html; charset=utf-8" lf-file /var/vcap/jobs/haproxy/errorpages/0-503.html')
+    end
+    expect(frontend_http.grep(/http-response return status 503/)).to be_empty
+  end
+
+  it 'overrides the pages for routed backends' do
+    expect(backend_api).to include('http-error status 500 content-type "application/json" lf-file /var/vcap/jobs/haproxy/errorpages/2-500.json')
+    expect(backend_api.index('http-error status 503 content-type "application/json" lf-file /var/vcap/jobs/haproxy/errorpages/2-503.json')).to be > backend_api.index('http-error status 503 content-type "text/html; charset=utf-8" lf-file /var/vcap/jobs/haproxy/errorpages/0-503.html')
+    expect(backend_api).to include('http-response return status 500 content-type "application/json" lf-file /var/vcap/jobs/haproxy/errorpages/2-500.json if { status 500 }')
+    expect(backend_http.grep(/errorpages\/2-/)).to be_empty
+  end
+
+  context 'when frontend_config sets a unique-id-format' do
+    let(:properties) do
+      default_properties.merge({ 'frontend_config' => "unique-id-format %[uuid()]\nunique-id-header X-Unique-ID" })
+    end
+
+    it 'keeps the unique-id-format of the operator' do
+      [frontend_http, frontend_https].each do |frontend|
+        expect(frontend.grep(/unique-id-format/)).to eq(['unique-id-format %[uuid()]'])
+        expect(frontend).to include('http-request set-var(txn.request_id) unique-id unless { var(txn.request_id) -m found }')
+      end
+    end
+  end
+
+  context 'when an intercepting page has HTML and JSON' do
+    let(:properties) do
+      { 'error_pages' => [{ 'statuses' => [502], 'intercept_backend_errors' => true, 'html' => '<h1>{{status}}</h1>', 'json' => '{"status": {{status}}}' }] }
+    end
+
+    it 'returns the JSON page to clients preferring JSON' do
+      expect(frontend_http).to include('http-response return status 502 content-type "application/json" lf-file /var/vcap/jobs/haproxy/errorpages/0-502.json if { status 502 } { var(txn.error_page_json) -m bool }')
+      expect(frontend_http).to include('http-response return status 502 content-type "text/html; charset=utf-8" lf-file /var/vcap/jobs/haproxy/errorpages/0-502.html if { status 502 } !{ var(txn.error_page_json) -m bool }')
+    end
+  end
+
+  context 'when error pages are not configured' do
+    let(:properties) { { 'ssl_pem' => 'ssl pem contents' } }
+
+    it 'does not add error page rules' do
+      expect(frontend_http.grep(/error_page|unique-id/)).to be_empty
+      expect(backend_http.grep(/http-error/)).to be_empty
+    end
+  end
+
+  context 'when a page is scoped to hosts without intercepting backend errors' do
+    let(:properties) do
+      { 'error_pages' => [{ 'statuses' => [503], 'hosts' => ['a.example.com'], 'html' => 'down' }] }
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect do
+        frontend_http
+      end.to raise_error(/Conflicting configuration: error_pages\[0\].hosts requires intercept_backend_errors/)
+    end
+  end
+
+  context 'when a page has an unsupported status' do
+    let(:properties) do
+      { 'error_pages' => [{ 'statuses' => [418], 'html' => 'teapot' }] }
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect do
+        frontend_http
+      end.to raise_error(/Conflicting configuration: error_pages\[0\].statuses must list status codes out of/)
+    end
+  end
+
+  context 'when a page refers to an unknown routed backend' do
+    let(:properties) do
+      { 'error_pages' => [{ 'statuses' => [503], 'routed_backends' => ['/unknown'], 'html' => 'down' }] }
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect do
+        frontend_http
+      end.to raise_error(/Conflicting configuration: error_pages\[0\].routed_backends contains '\/unknown', which is not in routed_backend_servers/)
+    end
+  end
+end
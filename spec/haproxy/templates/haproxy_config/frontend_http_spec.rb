@@ -33,6 +33,28 @@ describe 'config/haproxy.config HTTP frontend' do
       end
     end
 
+    context 'when ha_proxy.binding_ips is provided' do
+      let(:properties) do
+        { 'binding_ips' => ['0.0.0.0', '::', 'fd00::5'] }
+      end
+
+      it 'binds to each address and restricts the IPv6 wildcard address to IPv6' do
+        expect(frontend_http).to include('bind 0.0.0.0:80')
+        expect(frontend_http).to include('bind :::80  v6only')
+        expect(frontend_http).to include('bind fd00::5:80')
+      end
+
+      context 'when ha_proxy.v4v6 is true' do
+        let(:properties) do
+          { 'binding_ips' => ['0.0.0.0', '::'], 'v4v6' => true }
+        end
+
+        it 'aborts with a meaningful error message' do
+          expect { frontend_http }.to raise_error(/v4v6 accepts IPv4 on '::' already, binding_ips must not contain an IPv4 wildcard address as well/)
+        end
+      end
+    end
+
     context 'when ha_proxy.accept_proxy is true' do
       let(:properties) do
         { 'accept_proxy' => true }
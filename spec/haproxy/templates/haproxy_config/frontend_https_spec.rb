@@ -774,6 +774,21 @@ describe 'config/haproxy.config HTTPS frontend' do
       end
     end
 
+    context 'when binding to the IPv6 wildcard address with v4v6' do
+      let(:properties) do
+        default_properties.merge({
+          'true_client_ip_header' => 'X-CF-True-Client-IP',
+          'forward_true_client_ip_header' => 'always_set',
+          'binding_ip' => '::',
+          'v4v6' => true
+        })
+      end
+
+      it 'passes IPv4 clients on in their IPv4 form' do
+        expect(frontend_https).to include('http-request set-header X-CF-True-Client-IP %[src,regsub(^::ffff:,)]')
+      end
+    end
+
     context 'when ha_proxy.forward_true_client_ip_header is set to an invalid value' do
       let(:properties) do
         default_properties.merge({
@@ -828,6 +843,109 @@ describe 'config/haproxy.config HTTPS frontend' do
     end
   end
 
+  context 'when ha_proxy.enable_http3 is true' do
+    let(:properties) do
+      default_properties.merge({ 'enable_http3' => true })
+    end
+
+    it 'adds a quic bind with the same certificates' do
+      expect(frontend_https).to include('bind :443  ssl crt /var/vcap/jobs/haproxy/config/ssl')
+      expect(frontend_https).to include('bind quic4@:443 ssl crt /var/vcap/jobs/haproxy/config/ssl   alpn h3')
+    end
+
+    it 'advertises http/3 via the alt-svc header' do
+      expect(frontend_https).to include('http-after-response set-header alt-svc "h3=\":443\"; ma=86400"')
+    end
+
+    it 'enables quic with the openssl compatibility layer' do
+      expect(haproxy_conf['global']).to include('limited-quic')
+    end
+
+    context 'when ha_proxy.http3_alt_svc_max_age is provided' do
+      let(:properties) do
+        default_properties.merge({ 'enable_http3' => true, 'http3_alt_svc_max_age' => 3600 })
+      end
+
+      it 'uses the max-age in the alt-svc header' do
+        expect(frontend_https).to include('http-after-response set-header alt-svc "h3=\":443\"; ma=3600"')
+      end
+    end
+
+    context 'when ha_proxy.v4v6 is true and binding_ip is ::' do
+      let(:properties) do
+        default_properties.merge({ 'enable_http3' => true, 'v4v6' => true, 'binding_ip' => '::' })
+      end
+
+      it 'binds quic to ipv6' do
+        expect(frontend_https).to include('bind quic6@:::443 ssl crt /var/vcap/jobs/haproxy/config/ssl  v4v6 alpn h3')
+      end
+    end
+
+    context 'when ha_proxy.binding_ips contains IPv4 and IPv6 addresses' do
+      let(:properties) do
+        default_properties.merge({ 'enable_http3' => true, 'binding_ips' => ['10.0.0.5', 'fd00::5'] })
+      end
+
+      it 'binds quic to each address' do
+        expect(frontend_https).to include('bind quic4@10.0.0.5:443 ssl crt /var/vcap/jobs/haproxy/config/ssl   alpn h3')
+        expect(frontend_https).to include('bind quic6@fd00::5:443 ssl crt /var/vcap/jobs/haproxy/config/ssl   alpn h3')
+      end
+    end
+
+    context 'when ha_proxy.strict_sni is true' do
+      let(:properties) do
+        default_properties.merge({ 'enable_http3' => true, 'strict_sni' => true })
+      end
+
+      it 'enables strict-sni on the quic bind' do
+        expect(frontend_https).to include('bind quic4@:443 ssl crt /var/vcap/jobs/haproxy/config/ssl strict-sni  alpn h3')
+      end
+    end
+
+    context 'when mutual tls is enabled' do
+      let(:properties) do
+        default_properties.merge({ 'enable_http3' => true, 'client_cert' => true })
+      end
+
+      it 'verifies client certificates on the quic bind' do
+        expect(frontend_https).to include('bind quic4@:443 ssl crt /var/vcap/jobs/haproxy/config/ssl  ca-file /etc/ssl/certs/ca-certificates.crt verify optional  alpn h3')
+      end
+    end
+
+    context 'when ha_proxy.crt_list entries set alpn' do
+      let(:properties) do
+        {
+          'enable_http3' => true,
+          'enable_http2' => true,
+          'crt_list' => [{ 'ssl_pem' => 'ssl_pem contents', 'alpn' => ['h2', 'http/1.1'] }]
+        }
+      end
+
+      it 'uses the quic crt-list without the tcp alpn on the quic bind' do
+        expect(frontend_https).to include('bind :443  ssl crt-list /var/vcap/jobs/haproxy/config/ssl/crt-list   alpn h2,http/1.1')
+        expect(frontend_https).to include('bind quic4@:443 ssl crt-list /var/vcap/jobs/haproxy/config/ssl/crt-list-quic   alpn h3')
+      end
+    end
+
+    context 'when no ssl options are provided' do
+      let(:properties) { { 'enable_http3' => true } }
+
+      it 'aborts with a meaningful error message' do
+        expect do
+          haproxy_conf
+        end.to raise_error(/Conflicting configuration: if enable_http3 is true, you must provide a valid SSL config via ssl_pem or crt_list/)
+      end
+    end
+  end
+
+  context 'when ha_proxy.enable_http3 is false (the default)' do
+    it 'does not add a quic bind' do
+      expect(frontend_https).not_to include(/quic/)
+      expect(frontend_https).not_to include(/alt-svc/)
+      expect(haproxy_conf['global']).not_to include('limited-quic')
+    end
+  end
+
   context 'when no ssl options are provided' do
     let(:properties) { {} }
 
@@ -202,4 +202,76 @@ describe 'config/haproxy.config custom TCP frontends' do
       end
     end
   end
+
+  context 'when unix_sockets are provided' do
+    let(:properties) do
+      {
+        'tcp' => [{
+          'name' => 'redis',
+          'unix_sockets' => [{ 'path' => '/var/vcap/sys/run/haproxy-sidecar/redis.sock', 'mode' => '660' }, 'abns@redis'],
+          'backend_port' => 6379,
+          'backend_servers' => ['10.0.0.1']
+        }]
+      }
+    end
+
+    it 'only binds to the sockets' do
+      expect(frontend_tcp_redis).to include('bind /var/vcap/sys/run/haproxy-sidecar/redis.sock mode 660')
+      expect(frontend_tcp_redis).to include('bind abns@redis')
+      expect(frontend_tcp_redis).not_to include(match(/bind :/))
+    end
+
+    context 'when ssl is enabled' do
+      let(:properties) do
+        {
+          'ssl_pem' => 'ssl pem contents',
+          'tcp' => [{
+            'name' => 'redis',
+            'port' => 6379,
+            'ssl' => true,
+            'unix_sockets' => ['/var/vcap/sys/run/haproxy-sidecar/redis.sock'],
+            'backend_servers' => ['10.0.0.1']
+          }]
+        }
+      end
+
+      it 'terminates TLS on the sockets as well' do
+        expect(frontend_tcp_redis).to include('bind :6379  ssl crt /var/vcap/jobs/haproxy/config/ssl')
+        expect(frontend_tcp_redis).to include('bind /var/vcap/sys/run/haproxy-sidecar/redis.sock ssl crt /var/vcap/jobs/haproxy/config/ssl')
+      end
+    end
+
+    context 'when the backend_port is missing' do
+      let(:properties) do
+        { 'tcp' => [{ 'name' => 'redis', 'unix_sockets' => ['abns@redis'], 'backend_servers' => ['10.0.0.1'] }] }
+      end
+
+      it 'aborts with a meaningful error message' do
+        expect { haproxy_conf }.to raise_error(/tcp.redis.backend_port must be set if it only listens on UNIX sockets/)
+      end
+    end
+
+    context 'when a socket is bound by a frontend as well' do
+      let(:properties) do
+        {
+          'frontends' => [{ 'name' => 'sidecar', 'unix_sockets' => ['abns@redis'] }],
+          'tcp' => [{ 'name' => 'redis', 'unix_sockets' => ['abns@redis'], 'backend_port' => 6379, 'backend_servers' => ['10.0.0.1'] }]
+        }
+      end
+
+      it 'aborts with a meaningful error message' do
+        expect { haproxy_conf }.to raise_error(/tcp.redis.unix_sockets binds abns@redis, which is already bound/)
+      end
+    end
+  end
+
+  context 'when neither port nor unix_sockets are provided' do
+    let(:properties) do
+      { 'tcp' => [{ 'name' => 'redis', 'backend_servers' => ['10.0.0.1'] }] }
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect { haproxy_conf }.to raise_error(/tcp.redis.port must be set/)
+    end
+  end
 end
@@ -0,0 +1,331 @@
+# frozen_string_literal: true
+
+require 'rspec'
+
+describe 'config/haproxy.config named frontends' do
+  let(:haproxy_conf) do
+    parse_haproxy_config(template.render({ 'ha_proxy' => properties }))
+  end
+
+  let(:default_properties) do
+    {
+      'frontends' => [{
+        'name' => 'public',
+        'port' => 8080,
+        'cidr_blacklist' => ['10.0.0.0/8'],
+        'headers' => { 'X-Frontend' => 'public' }
+      }, {
+        'name' => 'internal',
+        'port' => 8443,
+        'bind' => '10.0.1.5',
+        'ssl_pem' => 'internal cert',
+        'ssl_min_ver' => 'TLSv1.2',
+        'ssl_ciphers' => 'ECDHE-RSA-AES128-GCM-SHA256',
+        'client_cert' => 'required',
+        'client_ca_file' => 'internal ca',
+        'cidr_whitelist' => ['10.0.0.0/8'],
+        'block_all' => true,
+        'strip_headers' => ['X-Internal'],
+        'rsp_headers' => { 'X-Served-By' => 'internal' }
+      }]
+    }
+  end
+
+  let(:properties) { default_properties }
+
+  let(:frontend_public) { haproxy_conf['frontend http-frontend_public'] }
+  let(:frontend_internal) { haproxy_conf['frontend http-frontend_internal'] }
+
+  context 'when ha_proxy.frontends is not provided' do
+    let(:properties) { {} }
+
+    it 'renders no named frontends' do
+      expect(haproxy_conf.keys).not_to include(match(/http-frontend_/))
+    end
+  end
+
+  it 'binds each frontend to its own address and port' do
+    expect(frontend_public).to include('bind :8080')
+    expect(frontend_internal).to include('bind 10.0.1.5:8443 ssl crt /var/vcap/jobs/haproxy/config/frontends/internal-cert-0.pem ssl-min-ver TLSv1.2 ciphers ECDHE-RSA-AES128-GCM-SHA256 ca-file /var/vcap/jobs/haproxy/config/frontends/internal-client-ca.pem verify required')
+  end
+
+  it 'applies the access control of each frontend' do
+    expect(frontend_public).to include('acl blacklist src 10.0.0.0/8')
+    expect(frontend_public).to include('tcp-request content reject if blacklist')
+    expect(frontend_public).not_to include('tcp-request content reject')
+
+    expect(frontend_internal).to include('acl whitelist src 10.0.0.0/8')
+    expect(frontend_internal).to include('tcp-request content accept if whitelist')
+    expect(frontend_internal).to include('tcp-request content reject')
+  end
+
+  it 'applies the headers of each frontend' do
+    expect(frontend_public).to include('http-request add-header X-Frontend "public"')
+    expect(frontend_public).to include('http-request add-header X-Forwarded-Proto "http" if ! xfp_exists')
+    expect(frontend_internal).to include('http-request del-header X-Internal')
+    expect(frontend_internal).to include('http-response add-header X-Served-By "internal"')
+    expect(frontend_internal).to include('http-request add-header X-Forwarded-Proto "https" if ! xfp_exists')
+  end
+
+  it 'sets X-Forwarded-Client-Cert only from client certificates' do
+    expect(frontend_public).to include('http-request del-header X-Forwarded-Client-Cert')
+    expect(frontend_public).not_to include(match(/set-header X-Forwarded-Client-Cert/))
+    expect(frontend_internal).to include('http-request del-header X-Forwarded-Client-Cert')
+    expect(frontend_internal).to include('http-request set-header X-Forwarded-Client-Cert %[ssl_c_der,base64] if { ssl_c_used }')
+  end
+
+  it 'uses the default HTTP backend' do
+    expect(frontend_public).to include('default_backend http-routers-http1')
+    expect(frontend_internal).to include('default_backend http-routers-http1')
+  end
+
+  context 'when global frontend options are provided' do
+    let(:properties) do
+      default_properties.merge({
+        'cidr_whitelist' => ['192.168.0.0/16'],
+        'headers' => { 'X-Global' => 'true' }
+      })
+    end
+
+    it 'does not apply them to named frontends' do
+      expect(frontend_public).not_to include(match(/whitelist_cidrs.txt/))
+      expect(frontend_public).not_to include(match(/X-Global/))
+    end
+  end
+
+  context 'when a default_backend is a prefix of routed_backend_servers' do
+    let(:properties) do
+      default_properties.merge({
+        'routed_backend_servers' => { '/images' => { 'port' => 12_000, 'servers' => ['10.0.0.1'] } },
+        'frontends' => [{ 'name' => 'images', 'port' => 8080, 'default_backend' => '/images' }]
+      })
+    end
+
+    it 'uses the routed backend' do
+      expect(haproxy_conf['frontend http-frontend_images']).to include('default_backend http-routed-backend-9c1bb7')
+    end
+  end
+
+  context 'when a default_backend is a prefix which is not in routed_backend_servers' do
+    let(:properties) do
+      { 'frontends' => [{ 'name' => 'images', 'port' => 8080, 'default_backend' => '/images' }] }
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect { haproxy_conf }.to raise_error(/frontends.images.default_backend '\/images' is not in routed_backend_servers/)
+    end
+  end
+
+  context 'when http_request_deny_conditions are provided' do
+    let(:properties) do
+      { 'frontends' => [{
+        'name' => 'public',
+        'port' => 8080,
+        'http_request_deny_conditions' => [{
+          'condition' => [{ 'acl_name' => 'admin', 'acl_rule' => 'path_beg /admin' }]
+        }]
+      }] }
+    end
+
+    it 'denies matching requests' do
+      expect(frontend_public).to include('acl admin path_beg /admin')
+      expect(frontend_public).to include('http-request deny if admin')
+    end
+  end
+
+  context 'when alpn is provided' do
+    let(:properties) do
+      { 'frontends' => [{ 'name' => 'internal', 'port' => 8443, 'ssl_pem' => 'cert', 'alpn' => ['h2', 'http/1.1'] }] }
+    end
+
+    it 'sets alpn on the bind' do
+      expect(frontend_internal).to include('bind :8443 ssl crt /var/vcap/jobs/haproxy/config/frontends/internal-cert-0.pem alpn h2,http/1.1')
+    end
+  end
+
+  context 'when config is provided' do
+    let(:properties) do
+      { 'frontends' => [{ 'name' => 'public', 'port' => 8080, 'config' => "timeout client 10s\noption forwardfor" }] }
+    end
+
+    it 'includes the raw config' do
+      expect(frontend_public).to include('timeout client 10s')
+      expect(frontend_public).to include('option forwardfor')
+    end
+  end
+
+  context 'when accept_proxy is enabled for a frontend' do
+    let(:properties) do
+      { 'frontends' => [{ 'name' => 'public', 'port' => 8080, 'accept_proxy' => true }] }
+    end
+
+    it 'expects the proxy protocol and rejects blocked sources per session' do
+      expect(frontend_public).to include('bind :8080 accept-proxy')
+      expect(frontend_public).to include('tcp-request session reject if layer4_block')
+    end
+  end
+
+  context 'when a frontend binds the port of another frontend' do
+    let(:properties) do
+      { 'frontends' => [{ 'name' => 'public', 'port' => 80, 'bind' => '10.0.1.5' }] }
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect { haproxy_conf }.to raise_error(/frontends.public binds 10.0.1.5:80, which is also bound by http-in/)
+    end
+  end
+
+  context 'when two frontends bind the same port on different addresses' do
+    let(:properties) do
+      { 'frontends' => [
+        { 'name' => 'public', 'port' => 8080, 'bind' => '10.0.1.5' },
+        { 'name' => 'internal', 'port' => 8080, 'bind' => '10.0.2.5' }
+      ] }
+    end
+
+    it 'renders both' do
+      expect(frontend_public).to include('bind 10.0.1.5:8080')
+      expect(frontend_internal).to include('bind 10.0.2.5:8080')
+    end
+  end
+
+  context 'when a name is used twice' do
+    let(:properties) do
+      { 'frontends' => [{ 'name' => 'public', 'port' => 8080 }, { 'name' => 'public', 'port' => 8081 }] }
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect { haproxy_conf }.to raise_error(/frontends contains the name 'public' more than once/)
+    end
+  end
+
+  context 'when a name is invalid' do
+    let(:properties) do
+      { 'frontends' => [{ 'name' => 'Public Frontend', 'port' => 8080 }] }
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect { haproxy_conf }.to raise_error(/frontends\[0\] needs a name consisting of lowercase letters, digits, '-' and '_'/)
+    end
+  end
+
+  context 'when the port is missing' do
+    let(:properties) do
+      { 'frontends' => [{ 'name' => 'public' }] }
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect { haproxy_conf }.to raise_error(/frontends.public.port must be set/)
+    end
+  end
+
+  context 'when TLS options are provided without ssl_pem' do
+    let(:properties) do
+      { 'frontends' => [{ 'name' => 'public', 'port' => 8080, 'ssl_min_ver' => 'TLSv1.2' }] }
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect { haproxy_conf }.to raise_error(/frontends.public.ssl_pem must be set to use ssl_min_ver/)
+    end
+  end
+
+  context 'when client_cert is provided without ssl_pem' do
+    let(:properties) do
+      { 'frontends' => [{ 'name' => 'public', 'port' => 8080, 'client_cert' => true }] }
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect { haproxy_conf }.to raise_error(/frontends.public.ssl_pem must be set to use client_cert/)
+    end
+  end
+
+  context 'when client_cert has an unknown value' do
+    let(:properties) do
+      { 'frontends' => [{ 'name' => 'internal', 'port' => 8443, 'ssl_pem' => 'cert', 'client_cert' => 'always' }] }
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect { haproxy_conf }.to raise_error(/Unknown 'frontends.internal.client_cert' option: always/)
+    end
+  end
+
+  context 'when cidr_whitelist is not a list' do
+    let(:properties) do
+      { 'frontends' => [{ 'name' => 'public', 'port' => 8080, 'cidr_whitelist' => 'H4sIAAAAAAAAAzMEAA==' }] }
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect { haproxy_conf }.to raise_error(/frontends.public.cidr_whitelist must be a non-empty list of CIDRs/)
+    end
+  end
+
+  context 'when unix_sockets are provided' do
+    let(:properties) do
+      { 'frontends' => [{
+        'name' => 'internal',
+        'unix_sockets' => [
+          { 'path' => '/var/vcap/sys/run/haproxy-sidecar/internal.sock', 'user' => 'vcap', 'group' => 'vcap', 'mode' => '660' },
+          'abns@internal'
+        ],
+        'accept_proxy' => true
+      }] }
+    end
+
+    it 'only binds to the sockets' do
+      expect(frontend_internal).to include('bind /var/vcap/sys/run/haproxy-sidecar/internal.sock user vcap group vcap mode 660 accept-proxy')
+      expect(frontend_internal).to include('bind abns@internal accept-proxy')
+      expect(frontend_internal).not_to include(match(/bind :/))
+    end
+  end
+
+  context 'when a port is provided next to unix_sockets' do
+    let(:properties) do
+      { 'frontends' => [{ 'name' => 'internal', 'port' => 8080, 'unix_sockets' => ['abns@internal'] }] }
+    end
+
+    it 'binds to both' do
+      expect(frontend_internal).to include('bind :8080')
+      expect(frontend_internal).to include('bind abns@internal')
+    end
+  end
+
+  context 'when an abstract namespace socket has a mode' do
+    let(:properties) do
+      { 'frontends' => [{ 'name' => 'internal', 'unix_sockets' => [{ 'path' => 'abns@internal', 'mode' => '660' }] }] }
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect { haproxy_conf }.to raise_error(/frontends.internal.unix_sockets\[0\].mode cannot be used with the abstract namespace socket abns@internal/)
+    end
+  end
+
+  context 'when a socket path is relative' do
+    let(:properties) do
+      { 'frontends' => [{ 'name' => 'internal', 'unix_sockets' => ['internal.sock'] }] }
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect { haproxy_conf }.to raise_error(/frontends.internal.unix_sockets\[0\].path must be an absolute path or start with 'abns@', got 'internal.sock'/)
+    end
+  end
+
+  context 'when a socket mode is not octal' do
+    let(:properties) do
+      { 'frontends' => [{ 'name' => 'internal', 'unix_sockets' => [{ 'path' => '/var/vcap/sys/run/haproxy-sidecar/internal.sock', 'mode' => 'rw-rw----' }] }] }
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect { haproxy_conf }.to raise_error(/frontends.internal.unix_sockets\[0\].mode must be an octal file mode such as '660', got 'rw-rw----'/)
+    end
+  end
+
+  context 'when a frontend only has unix_sockets and a bind' do
+    let(:properties) do
+      { 'frontends' => [{ 'name' => 'internal', 'bind' => '10.0.1.5', 'unix_sockets' => ['abns@internal'] }] }
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect { haproxy_conf }.to raise_error(/frontends.internal.port must be set/)
+    end
+  end
+end
@@ -0,0 +1,113 @@
+# frozen_string_literal: true
+
+require 'rspec'
+
+describe 'config/haproxy.config geoip' do
+  let(:haproxy_conf) do
+    parse_haproxy_config(template.render({ 'ha_proxy' => properties }))
+  end
+
+  let(:default_properties) do
+    {
+      'geoip' => {
+        'country_database' => '/var/vcap/data/geoip/country.mmdb'
+      }
+    }
+  end
+
+  let(:properties) { default_properties }
+
+  let(:frontend_http) { haproxy_conf['frontend http-in'] }
+  let(:frontend_https) { haproxy_conf['frontend https-in'] }
+
+  context 'when ha_proxy.geoip.country_database is not provided' do
+    let(:properties) { {} }
+
+    it 'does not look up the client country' do
+      expect(frontend_http).not_to include(match(/client_country/))
+    end
+  end
+
+  it 'looks up the client country and sets the country header' do
+    expect(frontend_http).to include('http-request set-var(txn.client_country) src,map_ip(/var/vcap/jobs/haproxy/config/geoip/country.map)')
+    expect(frontend_http).to include('http-request del-header X-Client-Country')
+    expect(frontend_http).to include('http-request set-header X-Client-Country %[var(txn.client_country)] if { var(txn.client_country) -m found }')
+  end
+
+  context 'when ha_proxy.geoip.country_header is empty' do
+    let(:properties) do
+      default_properties.deep_merge({ 'geoip' => { 'country_header' => '' } })
+    end
+
+    it 'does not set the country header' do
+      expect(frontend_http).to include('http-request set-var(txn.client_country) src,map_ip(/var/vcap/jobs/haproxy/config/geoip/country.map)')
+      expect(frontend_http).not_to include(match(/X-Client-Country/))
+    end
+  end
+
+  context 'when ha_proxy.geoip.allow_countries is provided' do
+    let(:properties) do
+      default_properties.deep_merge({ 'geoip' => { 'allow_countries' => %w[DE FR] } })
+    end
+
+    it 'denies requests from other countries' do
+      expect(frontend_http).to include('http-request set-var-fmt(txn.block_reason) "blocked: country not allowed" unless { var(txn.client_country) -m str DE FR }')
+      expect(frontend_http).to include('http-request deny unless { var(txn.client_country) -m str DE FR }')
+      expect(frontend_https).to include('http-request deny unless { var(txn.client_country) -m str DE FR }')
+    end
+  end
+
+  context 'when ha_proxy.geoip.deny_countries is provided' do
+    let(:properties) do
+      default_properties.deep_merge({ 'geoip' => { 'deny_countries' => %w[XX] } })
+    end
+
+    it 'denies requests from these countries' do
+      expect(frontend_http).to include('http-request set-var-fmt(txn.block_reason) "blocked: country denied" if { var(txn.client_country) -m str XX }')
+      expect(frontend_http).to include('http-request deny if { var(txn.client_country) -m str XX }')
+    end
+  end
+
+  context 'when both allow_countries and deny_countries are provided' do
+    let(:properties) do
+      default_properties.deep_merge({ 'geoip' => { 'allow_countries' => %w[DE], 'deny_countries' => %w[XX] } })
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect { frontend_http }.to raise_error(/geoip.allow_countries and geoip.deny_countries are mutually exclusive/)
+    end
+  end
+
+  context 'when deny_countries is provided without a country database' do
+    let(:properties) do
+      { 'geoip' => { 'deny_countries' => %w[XX] } }
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect { frontend_http }.to raise_error(/geoip.country_database must be set to use geoip.allow_countries or geoip.deny_countries/)
+    end
+  end
+
+  context 'when ha_proxy.geoip.asn_database is provided' do
+    let(:properties) do
+      { 'geoip' => { 'asn_database' => '/var/vcap/data/geoip/asn.mmdb', 'deny_asns' => [64_512, 64_513] } }
+    end
+
+    it 'looks up the client ASN and denies the provided ASNs' do
+      expect(frontend_http).to include('http-request set-var(txn.client_asn) src,map_ip(/var/vcap/jobs/haproxy/config/geoip/asn.map)')
+      expect(frontend_http).to include('http-request set-var-fmt(txn.block_reason) "blocked: ASN denied" if { var(txn.client_asn) -m str 64512 64513 }')
+      expect(frontend_http).to include('http-request deny if { var(txn.client_asn) -m str 64512 64513 }')
+      expect(frontend_http).not_to include(match(/client_country/))
+    end
+  end
+
+  context 'when allow_asns is provided without an ASN database' do
+    let(:properties) do
+      { 'geoip' => { 'allow_asns' => [64_512] } }
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect { frontend_http }.to raise_error(/geoip.asn_database must be set to use geoip.allow_asns or geoip.deny_asns/)
+    end
+  end
+end
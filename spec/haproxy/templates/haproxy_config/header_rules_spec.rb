@@ -0,0 +1,148 @@
+# frozen_string_literal: true
+
+require 'rspec'
+
+describe 'config/haproxy.config header rules' do
+  let(:haproxy_conf) do
+    parse_haproxy_config(template.render({ 'ha_proxy' => properties }))
+  end
+
+  let(:frontend_http) { haproxy_conf['frontend http-in'] }
+  let(:frontend_https) { haproxy_conf['frontend https-in'] }
+  let(:backend_images) { haproxy_conf['backend http-routed-backend-9c1bb7'] }
+
+  let(:default_properties) do
+    {
+      'ssl_pem' => 'ssl pem contents',
+      'routed_backend_servers' => {
+        '/images' => {
+          'servers' => ['10.0.0.2'],
+          'port' => '443'
+        }
+      }
+    }
+  end
+
+  let(:properties) do
+    default_properties.merge({
+      'header_rules' => [
+        {
+          'action' => 'set',
+          'name' => 'X-Internal-Client',
+          'value' => 'true',
+          'conditions' => {
+            'hosts' => ['API.example.com'],
+            'paths' => ['/admin', '/internal'],
+            'methods' => %w[POST PUT],
+            'src_cidrs' => ['10.0.0.0/8'],
+            'header_absent' => 'X-Internal-Client'
+          }
+        },
+        {
+          'action' => 'add',
+          'name' => 'X-Client-IP',
+          'value' => '%[src]'
+        },
+        {
+          'action' => 'del',
+          'direction' => 'response',
+          'name' => 'Server'
+        },
+        {
+          'action' => 'replace-value',
+          'direction' => 'response',
+          'name' => 'Location',
+          'regex' => '^http://(.*)$',
+          'replacement' => 'https://\1',
+          'conditions' => {
+            'hosts' => ['app.example.com'],
+            'header_present' => 'Location'
+          },
+          'routed_backends' => ['/images']
+        }
+      ]
+    })
+  end
+
+  it 'adds the frontend rules in order' do
+    [frontend_http, frontend_https].each do |frontend|
+      rules = frontend.select { |line| line.match?(/X-Internal-Client|X-Client-IP|del-header Server/) }
+      expect(rules).to eq([
+        'http-request set-header X-Internal-Client "true" if { req.hdr(host),host_only,lower -m str api.example.com } { path_beg /admin /internal } { method POST PUT } { src 10.0.0.0/8 } !{ req.hdr(X-Internal-Client) -m found }',
+        'http-request add-header X-Client-IP "%[src]"',
+        'http-response del-header Server'
+      ])
+    end
+  end
+
+  it 'adds the rules scoped to a routed backend to that backend only' do
+    expect(backend_images).to include('http-request set-var(txn.header_rule_3) bool(true) if { req.hdr(host),host_only,lower -m str app.example.com }')
+    expect(backend_images).to include("http-response replace-value Location '^http://(.*)$' 'https://\\1' if { var(txn.header_rule_3) -m bool } { res.hdr(Location) -m found }")
+    expect(frontend_http).not_to include(/Location/)
+  end
+
+  context 'when a regex is invalid' do
+    let(:properties) do
+      default_properties.merge({
+        'header_rules' => [{ 'action' => 'replace-header', 'name' => 'X-Foo', 'regex' => '(unclosed', 'replacement' => 'x' }]
+      })
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect do
+        frontend_http
+      end.to raise_error(/Conflicting configuration: header_rules\[0\].regex is not a valid regular expression/)
+    end
+  end
+
+  context 'when the action is unknown' do
+    let(:properties) do
+      default_properties.merge({
+        'header_rules' => [{ 'action' => 'append', 'name' => 'X-Foo' }]
+      })
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect do
+        frontend_http
+      end.to raise_error(/Unknown 'header_rules\[0\].action' option: append. Known options: 'set', 'add', 'del', 'replace-value', 'replace-header'/)
+    end
+  end
+
+  context 'when the direction is unknown' do
+    let(:properties) do
+      default_properties.merge({
+        'header_rules' => [{ 'action' => 'del', 'name' => 'X-Foo', 'direction' => 'both' }]
+      })
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect do
+        frontend_http
+      end.to raise_error(/Unknown 'header_rules\[0\].direction' option: both. Known options: 'request', 'response'/)
+    end
+  end
+
+  context 'when a rule is scoped to an unknown routed backend' do
+    let(:properties) do
+      default_properties.merge({
+        'header_rules' => [{ 'action' => 'del', 'name' => 'X-Foo', 'routed_backends' => ['/videos'] }]
+      })
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect do
+        frontend_http
+      end.to raise_error(%r{Conflicting configuration: header_rules\[0\].routed_backends contains '/videos', which is not in routed_backend_servers})
+    end
+  end
+
+  context 'when ha_proxy.header_rules is not provided' do
+    let(:properties) { default_properties }
+
+    it 'does not add header rules' do
+      expect(frontend_http).not_to include(/header_rule/)
+      expect(backend_images).not_to include(/header_rule/)
+    end
+  end
+end
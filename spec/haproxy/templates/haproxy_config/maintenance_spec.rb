@@ -0,0 +1,83 @@
+# frozen_string_literal: true
+
+require 'rspec'
+
+describe 'config/haproxy.config maintenance mode' do
+  let(:haproxy_conf) do
+    parse_haproxy_config(template.render({ 'ha_proxy' => properties }))
+  end
+
+  let(:global) { haproxy_conf['global'] }
+  let(:frontend_http) { haproxy_conf['frontend http-in'] }
+  let(:frontend_https) { haproxy_conf['frontend https-in'] }
+
+  let(:frontend_wss) { haproxy_conf['frontend wss-in'] }
+  let(:frontend_internal) { haproxy_conf['frontend http-frontend_internal'] }
+
+  let(:properties) do
+    {
+      'ssl_pem' => 'ssl pem contents',
+      'enable_4443' => true,
+      'frontends' => [{ 'name' => 'internal', 'port' => 8443 }],
+      'maintenance' => { 'enabled' => true }
+    }
+  end
+
+  it 'sets the initial global maintenance state' do
+    expect(global).to include('set-var proc.maintenance_all bool(false)')
+  end
+
+  it 'answers requests in maintenance with the maintenance page' do
+    [frontend_http, frontend_https, frontend_wss, frontend_internal].each do |frontend|
+      expect(frontend).to include('acl maintenance var(proc.maintenance_all) -m bool')
+      expect(frontend).to include('acl maintenance req.hdr(host),host_only,lower -f /var/vcap/jobs/haproxy/config/maintenance_hosts.txt')
+      expect(frontend).to include('acl maintenance path_beg -f /var/vcap/jobs/haproxy/config/maintenance_path_prefixes.txt')
+      expect(frontend).to include('acl maintenance_bypass src -f /var/vcap/jobs/haproxy/config/maintenance_bypass_cidrs.txt')
+      expect(frontend).to include('http-request return status 503 content-type "text/html; charset=utf-8" file /var/vcap/jobs/haproxy/config/maintenance_page hdr Retry-After 300 hdr Cache-Control no-store if maintenance !maintenance_bypass')
+    end
+  end
+
+  context 'when all requests are initially in maintenance' do
+    let(:properties) do
+      { 'maintenance' => { 'enabled' => true, 'all' => true } }
+    end
+
+    it 'sets the global maintenance state' do
+      expect(global).to include('set-var proc.maintenance_all bool(true)')
+    end
+  end
+
+  context 'when a JSON page, another status and no Retry-After are configured' do
+    let(:properties) do
+      { 'maintenance' => { 'enabled' => true, 'status' => 502, 'retry_after' => 0, 'content_type' => 'application/json' } }
+    end
+
+    it 'returns the configured status and content type' do
+      expect(frontend_http).to include('http-request return status 502 content-type "application/json" file /var/vcap/jobs/haproxy/config/maintenance_page hdr Cache-Control no-store if maintenance !maintenance_bypass')
+    end
+  end
+
+  context 'when the status is not a 5xx status code' do
+    let(:properties) do
+      { 'maintenance' => { 'enabled' => true, 'status' => 200 } }
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect do
+        frontend_http
+      end.to raise_error(/Conflicting configuration: maintenance.status must be a 5xx status code/)
+    end
+  end
+
+  context 'when maintenance mode is not enabled' do
+    let(:properties) { { 'ssl_pem' => 'ssl pem contents', 'enable_4443' => true, 'frontends' => [{ 'name' => 'internal', 'port' => 8443 }] } }
+
+    it 'does not add maintenance rules' do
+      expect(global.grep(/maintenance/)).to be_empty
+      expect(frontend_http.grep(/maintenance/)).to be_empty
+      expect(frontend_https.grep(/maintenance/)).to be_empty
+      expect(frontend_wss.grep(/maintenance/)).to be_empty
+      expect(frontend_internal.grep(/maintenance/)).to be_empty
+    end
+  end
+end
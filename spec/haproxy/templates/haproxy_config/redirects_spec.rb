@@ -0,0 +1,119 @@
+# frozen_string_literal: true
+
+require 'rspec'
+
+describe 'config/haproxy.config redirects and rewrites' do
+  let(:haproxy_conf) do
+    parse_haproxy_config(template.render({ 'ha_proxy' => properties }))
+  end
+
+  let(:frontend_http) { haproxy_conf['frontend http-in'] }
+  let(:frontend_https) { haproxy_conf['frontend https-in'] }
+
+  let(:default_properties) do
+    {
+      'ssl_pem' => 'ssl pem contents',
+      'redirects' => [
+        { 'host' => 'old.example.com', 'target' => 'https://new.example.com' },
+        { 'path' => '/pricing', 'target' => 'https://www.example.com/plans', 'code' => 302 },
+        { 'prefix' => '/docs/v1/', 'target' => '/docs/v2/', 'code' => 308 },
+        { 'regex' => '^/blog/([0-9]+)/([a-z-]+)$', 'target' => '/articles/\2-\1', 'code' => 307, 'preserve_query' => false }
+      ],
+      'rewrites' => [
+        { 'prefix' => '/api/v1/', 'target' => '/v1/' },
+        { 'regex' => '^/static/[0-9a-f]+/(.*)$', 'target' => '/static/\1' }
+      ]
+    }
+  end
+
+  let(:properties) { default_properties }
+
+  it 'looks up host, path and prefix redirects in the map files' do
+    [frontend_http, frontend_https].each do |frontend|
+      expect(frontend).to include('http-request set-var(txn.redirect) req.hdr(host),host_only,lower,map(/var/vcap/jobs/haproxy/config/redirect_hosts.map)')
+      expect(frontend).to include('http-request set-var(txn.redirect) path,map(/var/vcap/jobs/haproxy/config/redirect_paths.map) unless { var(txn.redirect) -m found }')
+      expect(frontend).to include('http-request set-var(txn.redirect) path,map_beg(/var/vcap/jobs/haproxy/config/redirect_prefixes.map) unless { var(txn.redirect) -m found }')
+      expect(frontend).to include('http-request set-var-fmt(txn.redirect_location) "%[var(txn.redirect),field(2,|)]%[path,bytes(txn.redirect_prefix_length)]" if { var(txn.redirect_prefix_length) -m found }')
+    end
+  end
+
+  it 'computes the location of regex redirects' do
+    expect(frontend_http).to include("http-request set-var(txn.redirect_regex) int(3) if !{ var(txn.redirect_location) -m found } !{ var(txn.redirect_regex) -m found } { path_reg '^/blog/([0-9]+)/([a-z-]+)$' }")
+    expect(frontend_http).to include("http-request replace-path '^/blog/([0-9]+)/([a-z-]+)$' '/articles/\\2-\\1' if { var(txn.redirect_regex) -m int 3 }")
+    expect(frontend_http).to include('http-request set-var(txn.redirect_code) str(307) if { var(txn.redirect_regex) -m int 3 }')
+    expect(frontend_http).to include('http-request set-var(txn.redirect_query) str(0) if { var(txn.redirect_regex) -m int 3 }')
+  end
+
+  it 'preserves the query string and redirects with the configured code' do
+    expect(frontend_http).to include('http-request set-var-fmt(txn.redirect_location) "%[var(txn.redirect_location)]?%[query]" if { var(txn.redirect_location) -m found } { var(txn.redirect_query) -m str 1 } { query -m len gt 0 }')
+    [301, 302, 307, 308].each do |code|
+      expect(frontend_http).to include("http-request redirect location %[var(txn.redirect_location)] code #{code} if { var(txn.redirect_location) -m found } { var(txn.redirect_code) -m str #{code} }")
+    end
+  end
+
+  it 'rewrites paths before routing' do
+    [frontend_http, frontend_https].each do |frontend|
+      expect(frontend).to include('http-request set-var(txn.rewrite) path,map_beg(/var/vcap/jobs/haproxy/config/rewrite_prefixes.map)')
+      expect(frontend).to include('http-request set-path "%[var(txn.rewrite),field(1,|)]%[path,bytes(txn.rewrite_prefix_length)]" if { var(txn.rewrite) -m found }')
+      expect(frontend).to include("http-request replace-path '^/static/[0-9a-f]+/(.*)$' '/static/\\1'")
+      expect(frontend.index('http-request set-path "%[var(txn.rewrite),field(1,|)]%[path,bytes(txn.rewrite_prefix_length)]" if { var(txn.rewrite) -m found }')).to be < frontend.index('capture request header Host len 256')
+    end
+  end
+
+  context 'when no redirects or rewrites are configured' do
+    let(:properties) { { 'ssl_pem' => 'ssl pem contents' } }
+
+    it 'does not add redirect or rewrite rules' do
+      expect(frontend_http.grep(/txn\.redirect|txn\.rewrite/)).to be_empty
+      expect(frontend_https.grep(/txn\.redirect|txn\.rewrite/)).to be_empty
+    end
+  end
+
+  context 'when a redirect matches on more than one kind' do
+    let(:properties) do
+      { 'redirects' => [{ 'host' => 'a.example.com', 'path' => '/a', 'target' => '/b' }] }
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect do
+        frontend_http
+      end.to raise_error(/Conflicting configuration: redirects\[0\] must provide exactly one of host, path, prefix, regex/)
+    end
+  end
+
+  context 'when a redirect has an unknown code' do
+    let(:properties) do
+      { 'redirects' => [{ 'path' => '/a', 'target' => '/b', 'code' => 303 }] }
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect do
+        frontend_http
+      end.to raise_error(/Unknown 'redirects\[0\].code' option: 303. Known options: 301, 302, 307, 308/)
+    end
+  end
+
+  context 'when a rewrite target contains whitespace' do
+    let(:properties) do
+      { 'rewrites' => [{ 'prefix' => '/a', 'target' => '/b c' }] }
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect do
+        frontend_http
+      end.to raise_error(/Conflicting configuration: rewrites\[0\].target must be set and must not contain whitespace/)
+    end
+  end
+
+  context 'when a rewrite regex is invalid' do
+    let(:properties) do
+      { 'rewrites' => [{ 'regex' => '^/(a', 'target' => '/b' }] }
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect do
+        frontend_http
+      end.to raise_error(/Conflicting configuration: rewrites\[0\].regex is not a valid regular expression/)
+    end
+  end
+end
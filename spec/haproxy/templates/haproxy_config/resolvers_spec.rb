@@ -29,6 +29,30 @@ describe 'config/haproxy.config resolvers' do
       expect(resolvers_default).to include('nameserver private 10.1.1.1:53')
     end
 
+    it 'keeps the default payload size' do
+      expect(resolvers_default).not_to include(/accepted_payload_size/)
+    end
+
+    context 'when servers are discovered' do
+      let(:properties) do
+        default_properties.merge({
+          'tcp' => [{ 'name' => 'db', 'port' => 5432, 'discovery' => { 'name' => 'db.service.internal' } }]
+        })
+      end
+
+      it 'accepts responses with many records' do
+        expect(resolvers_default).to include('accepted_payload_size 8192')
+      end
+    end
+
+    context 'when a resolver address includes a port' do
+      let(:properties) { { 'resolvers' => [{ 'local' => 'tcp@127.0.0.1:5353' }] } }
+
+      it 'keeps the port' do
+        expect(resolvers_default).to include('nameserver local tcp@127.0.0.1:5353')
+      end
+    end
+
     context 'when ha_proxy.dns_hold is provided' do
       let(:properties) { default_properties.merge({ 'dns_hold' => '30s' }) }
 
@@ -0,0 +1,124 @@
+# frozen_string_literal: true
+
+require 'rspec'
+
+describe 'config/haproxy.config security headers' do
+  let(:haproxy_conf) do
+    parse_haproxy_config(template.render({ 'ha_proxy' => properties }))
+  end
+
+  let(:frontend_http) { haproxy_conf['frontend http-in'] }
+  let(:frontend_https) { haproxy_conf['frontend https-in'] }
+  let(:frontend_wss) { haproxy_conf['frontend wss-in'] }
+
+  let(:default_properties) do
+    {
+      'ssl_pem' => 'ssl pem contents',
+      'enable_4443' => true,
+      'security_headers' => { 'enabled' => true }
+    }
+  end
+
+  let(:properties) { default_properties }
+
+  it 'adds the preset headers if the backend did not set them' do
+    [frontend_http, frontend_https, frontend_wss].each do |frontend|
+      expect(frontend).to include(%q(http-response set-header Content-Security-Policy "default-src 'self'; frame-ancestors 'self'; object-src 'none'" if !{ res.hdr(Content-Security-Policy) -m found }))
+      expect(frontend).to include('http-response set-header X-Frame-Options "SAMEORIGIN" if !{ res.hdr(X-Frame-Options) -m found }')
+      expect(frontend).to include('http-response set-header X-Content-Type-Options "nosniff" if !{ res.hdr(X-Content-Type-Options) -m found }')
+      expect(frontend).to include('http-response set-header Referrer-Policy "strict-origin-when-cross-origin" if !{ res.hdr(Referrer-Policy) -m found }')
+      expect(frontend).to include('http-response set-header Permissions-Policy "camera=(), microphone=(), geolocation=(), payment=()" if !{ res.hdr(Permissions-Policy) -m found }')
+    end
+  end
+
+  it 'does not need the host of the request' do
+    expect(frontend_http).not_to include(/txn.security_headers_host/)
+  end
+
+  context 'when headers are provided' do
+    let(:properties) do
+      default_properties.deep_merge({
+        'security_headers' => {
+          'headers' => {
+            'X-Frame-Options' => 'DENY',
+            'Permissions-Policy' => '',
+            'Cross-Origin-Opener-Policy' => 'same-origin'
+          }
+        }
+      })
+    end
+
+    it 'merges them over the preset' do
+      expect(frontend_http).to include('http-response set-header X-Frame-Options "DENY" if !{ res.hdr(X-Frame-Options) -m found }')
+      expect(frontend_http).to include('http-response set-header Cross-Origin-Opener-Policy "same-origin" if !{ res.hdr(Cross-Origin-Opener-Policy) -m found }')
+      expect(frontend_http).not_to include(/Permissions-Policy/)
+    end
+  end
+
+  context 'when a header value contains special characters' do
+    let(:properties) do
+      default_properties.deep_merge({
+        'security_headers' => {
+          'headers' => { 'Content-Security-Policy' => 'script-src "x" $HOME 100%' }
+        }
+      })
+    end
+
+    it 'escapes them' do
+      expect(frontend_http).to include('http-response set-header Content-Security-Policy "script-src \"x\" \$HOME 100%%" if !{ res.hdr(Content-Security-Policy) -m found }')
+    end
+  end
+
+  context 'when csp_report_only is true' do
+    let(:properties) do
+      default_properties.deep_merge({ 'security_headers' => { 'csp_report_only' => true } })
+    end
+
+    it 'sends the policy as report only' do
+      expect(frontend_http).to include(%q(http-response set-header Content-Security-Policy-Report-Only "default-src 'self'; frame-ancestors 'self'; object-src 'none'" if !{ res.hdr(Content-Security-Policy-Report-Only) -m found }))
+      expect(frontend_http).not_to include(/set-header Content-Security-Policy "/)
+    end
+  end
+
+  context 'when host overrides are provided' do
+    let(:properties) do
+      default_properties.deep_merge({
+        'security_headers' => {
+          'host_overrides' => {
+            'Embed.example.com' => {
+              'X-Frame-Options' => '',
+              'Content-Security-Policy' => 'frame-ancestors https://partner.example.com'
+            },
+            'other.example.com' => {
+              'X-Frame-Options' => 'DENY'
+            }
+          }
+        }
+      })
+    end
+
+    it 'stores the host of the request' do
+      expect(frontend_http).to include('http-request set-var(txn.security_headers_host) req.hdr(host),host_only,lower')
+    end
+
+    it 'sets the headers of the host' do
+      expect(frontend_http).to include('http-response set-header Content-Security-Policy "frame-ancestors https://partner.example.com" if { var(txn.security_headers_host) -m str embed.example.com } !{ res.hdr(Content-Security-Policy) -m found }')
+      expect(frontend_http).to include('http-response set-header X-Frame-Options "DENY" if { var(txn.security_headers_host) -m str other.example.com } !{ res.hdr(X-Frame-Options) -m found }')
+    end
+
+    it 'excludes the overriding hosts from the preset' do
+      expect(frontend_http).to include('http-response set-header X-Frame-Options "SAMEORIGIN" if !{ var(txn.security_headers_host) -m str embed.example.com other.example.com } !{ res.hdr(X-Frame-Options) -m found }')
+      expect(frontend_http).to include(%q(http-response set-header Content-Security-Policy "default-src 'self'; frame-ancestors 'self'; object-src 'none'" if !{ var(txn.security_headers_host) -m str embed.example.com } !{ res.hdr(Content-Security-Policy) -m found }))
+      expect(frontend_http).to include('http-response set-header Referrer-Policy "strict-origin-when-cross-origin" if !{ res.hdr(Referrer-Policy) -m found }')
+    end
+  end
+
+  context 'when ha_proxy.security_headers.enabled is false (the default)' do
+    let(:properties) { { 'ssl_pem' => 'ssl pem contents' } }
+
+    it 'does not add security headers' do
+      expect(frontend_http).not_to include(/X-Frame-Options/)
+      expect(frontend_https).not_to include(/Content-Security-Policy/)
+    end
+  end
+end
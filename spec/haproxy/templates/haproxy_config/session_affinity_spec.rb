@@ -0,0 +1,205 @@
+# frozen_string_literal: true
+
+require 'rspec'
+
+describe 'config/haproxy.config session affinity' do
+  let(:haproxy_conf) do
+    parse_haproxy_config(template.render({ 'ha_proxy' => properties }))
+  end
+
+  let(:default_properties) do
+    {
+      'backend_servers' => ['10.0.0.1', '10.0.0.2'],
+      'routed_backend_servers' => {
+        '/images' => {
+          'servers' => ['10.0.0.3', '10.0.0.4'],
+          'port' => '443'
+        }
+      }
+    }
+  end
+
+  let(:properties) { default_properties }
+
+  let(:backend_http1) { haproxy_conf['backend http-routers-http1'] }
+  let(:backend_images) { haproxy_conf['backend http-routed-backend-9c1bb7'] }
+
+  context 'when ha_proxy.backend_session_affinity is not provided' do
+    it 'does not configure persistence' do
+      expect(backend_http1).not_to include(match(/^cookie /))
+      expect(backend_http1).not_to include(match(/^stick /))
+      expect(backend_http1).to include('server node0 10.0.0.1:80 check inter 1000')
+      expect(backend_images).to include('server node0 10.0.0.3:443 check inter 1000')
+    end
+  end
+
+  context 'when ha_proxy.backend_session_affinity mode is cookie' do
+    let(:properties) do
+      default_properties.merge({ 'backend_session_affinity' => { 'mode' => 'cookie' } })
+    end
+
+    it 'inserts a persistence cookie and redispatches by default' do
+      expect(backend_http1).to include('cookie SERVERID insert indirect nocache httponly')
+      expect(backend_http1).to include('option redispatch')
+    end
+
+    it 'assigns a cookie value to each server' do
+      expect(backend_http1).to include('server node0 10.0.0.1:80 cookie node0 check inter 1000')
+      expect(backend_http1).to include('server node1 10.0.0.2:80 cookie node1 check inter 1000')
+    end
+
+    it 'does not affect routed backends' do
+      expect(backend_images).not_to include(match(/^cookie /))
+      expect(backend_images).to include('server node0 10.0.0.3:443 check inter 1000')
+    end
+
+    context 'when cookie_name and cookie_secure are provided' do
+      let(:properties) do
+        default_properties.merge({
+          'backend_session_affinity' => { 'mode' => 'cookie', 'cookie_name' => 'LB_AFFINITY', 'cookie_secure' => true }
+        })
+      end
+
+      it 'uses the provided cookie name and adds the secure flag' do
+        expect(backend_http1).to include('cookie LB_AFFINITY insert indirect nocache httponly secure')
+      end
+    end
+
+    context 'when failover is persist' do
+      let(:properties) do
+        default_properties.merge({ 'backend_session_affinity' => { 'mode' => 'cookie', 'failover' => 'persist' } })
+      end
+
+      it 'keeps clients on the pinned server' do
+        expect(backend_http1).to include('option persist')
+        expect(backend_http1).not_to include('option redispatch')
+      end
+    end
+
+    context 'when failover is unknown' do
+      let(:properties) do
+        default_properties.merge({ 'backend_session_affinity' => { 'mode' => 'cookie', 'failover' => 'never' } })
+      end
+
+      it 'aborts with a meaningful error message' do
+        expect { backend_http1 }.to raise_error(/Unknown 'backend_session_affinity.failover' option: never/)
+      end
+    end
+  end
+
+  context 'when ha_proxy.backend_session_affinity mode is app_cookie' do
+    let(:properties) do
+      default_properties.merge({
+        'backend_session_affinity' => { 'mode' => 'app_cookie', 'cookie_name' => 'JSESSIONID', 'table_size' => '1m', 'expire' => '1h' }
+      })
+    end
+
+    it 'learns the server from the application cookie' do
+      expect(backend_http1).to include('stick-table type string len 64 size 1m expire 1h')
+      expect(backend_http1).to include('stick on req.cook(JSESSIONID)')
+      expect(backend_http1).to include('stick store-response res.cook(JSESSIONID)')
+    end
+
+    it 'does not assign cookie values to the servers' do
+      expect(backend_http1).to include('server node0 10.0.0.1:80 check inter 1000')
+    end
+
+    context 'when cookie_name is missing' do
+      let(:properties) do
+        default_properties.merge({ 'backend_session_affinity' => { 'mode' => 'app_cookie' } })
+      end
+
+      it 'aborts with a meaningful error message' do
+        expect { backend_http1 }.to raise_error(/backend_session_affinity.cookie_name must be set when mode is 'app_cookie'/)
+      end
+    end
+  end
+
+  context 'when ha_proxy.backend_session_affinity mode is source' do
+    let(:properties) do
+      default_properties.merge({ 'backend_session_affinity' => { 'mode' => 'source' } })
+    end
+
+    it 'pins clients by source address using the default table size and expiry' do
+      expect(backend_http1).to include('stick-table type ipv6 size 100k expire 30m')
+      expect(backend_http1).to include('stick on src')
+    end
+  end
+
+  context 'when session affinity is combined with a retry budget' do
+    let(:properties) do
+      default_properties.merge({
+        'backend_session_affinity' => { 'mode' => 'source' },
+        'retries' => 2,
+        'retry_policy' => { 'retry_on' => ['503'], 'budget' => {} },
+        'routed_backend_servers' => {
+          '/images' => {
+            'servers' => ['10.0.0.3', '10.0.0.4'],
+            'port' => '443',
+            'backend_session_affinity' => { 'mode' => 'app_cookie', 'cookie_name' => 'JSESSIONID' }
+          }
+        }
+      })
+    end
+
+    it 'keeps a single stick-table in each backend' do
+      [backend_http1, backend_images].each do |backend|
+        expect(backend.grep(/^stick-table /).size).to eq(1)
+      end
+    end
+
+    it 'counts the retries in a table of their own' do
+      expect(backend_http1).to include('http-request track-sc2 int(0) table st_retry_budget_http-routers-http1')
+      expect(backend_images).to include('http-request track-sc2 int(0) table st_retry_budget_http-routed-backend-9c1bb7')
+      ['http-routers-http1', 'http-routed-backend-9c1bb7'].each do |backend|
+        expect(haproxy_conf["backend st_retry_budget_#{backend}"]).to eq(['stick-table type integer size 1 expire 10s store http_req_rate(10s),gpc0_rate(10s)'])
+      end
+    end
+  end
+
+  context 'when ha_proxy.backend_session_affinity mode is unknown' do
+    let(:properties) do
+      default_properties.merge({ 'backend_session_affinity' => { 'mode' => 'header' } })
+    end
+
+    it 'aborts with a meaningful error message' do
+      expect { backend_http1 }.to raise_error(/Unknown 'backend_session_affinity.mode' option: header/)
+    end
+  end
+
+  context 'when backend_session_affinity is provided for a routed backend' do
+    let(:properties) do
+      default_properties.deep_merge({
+        'routed_backend_servers' => {
+          '/images' => {
+            'backend_session_affinity' => { 'mode' => 'cookie', 'cookie_name' => 'IMAGES' }
+          }
+        }
+      })
+    end
+
+    it 'configures persistence on the routed backend only' do
+      expect(backend_images).to include('cookie IMAGES insert indirect nocache httponly')
+      expect(backend_images).to include('option redispatch')
+      expect(backend_images).to include('server node0 10.0.0.3:443 cookie node0 check inter 1000')
+      expect(backend_images).to include('server node1 10.0.0.4:443 cookie node1 check inter 1000')
+      expect(backend_http1).not_to include(match(/^cookie /))
+    end
+
+    context 'when the routed mode is unknown' do
+      let(:properties) do
+        default_properties.deep_merge({
+          'routed_backend_servers' => {
+            '/images' => {
+              'backend_session_affinity' => { 'mode' => 'header' }
+            }
+          }
+        })
+      end
+
+      it 'aborts with a meaningful error message' do
+        expect { backend_images }.to raise_error(%r{Unknown 'routed_backend_servers./images.backend_session_affinity.mode' option: header})
+      end
+    end
+  end
+end
@@ -70,6 +70,16 @@ describe 'config/haproxy.config stats listener' do
       end
     end
 
+    context 'when ha_proxy.stats_bind is an IPv6 address' do
+      let(:properties) do
+        default_properties.merge({ 'stats_bind' => '::1:5000' })
+      end
+
+      it 'takes the port from after the last colon' do
+        expect(stats_listener).to include('bind ::1:5000')
+      end
+    end
+
     context 'when ha_proxy.stats_user is empty' do
       let(:properties) do
         default_properties.merge({ 'stats_user' => '' })
@@ -0,0 +1,26 @@
+# frozen_string_literal: true
+
+require 'rspec'
+
+describe 'config/host_routes.map' do
+  let(:template) { haproxy_job.template('config/host_routes.map') }
+
+  context 'when ha_proxy.host_routes is provided' do
+    it 'maps exact hosts to their keys' do
+      expect(template.render({
+        'ha_proxy' => {
+          'host_routes' => {
+            'App.Example.com' => { 'servers' => ['10.0.0.2'], 'port' => '80' },
+            '*.example.com' => { 'servers' => ['10.0.0.3'], 'port' => '80' }
+          }
+        }
+      })).to eq("app.example.com\tapp.example.com\n")
+    end
+  end
+
+  context 'when ha_proxy.host_routes is not provided' do
+    it 'is empty' do
+      expect(template.render({})).to be_a_blank_string
+    end
+  end
+end
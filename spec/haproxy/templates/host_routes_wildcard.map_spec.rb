@@ -0,0 +1,39 @@
+# frozen_string_literal: true
+
+require 'rspec'
+
+describe 'config/host_routes_wildcard.map' do
+  let(:template) { haproxy_job.template('config/host_routes_wildcard.map') }
+
+  context 'when ha_proxy.host_routes is provided' do
+    it 'maps wildcard hosts by domain suffix to their keys' do
+      expect(template.render({
+        'ha_proxy' => {
+          'host_routes' => {
+            'app.example.com' => { 'servers' => ['10.0.0.2'], 'port' => '80' },
+            '*.example.com' => { 'servers' => ['10.0.0.3'], 'port' => '80' }
+          }
+        }
+      })).to eq(".example.com\t.example.com\n")
+    end
+  end
+
+  context 'when wildcard hosts overlap' do
+    it 'lists the most specific suffix first' do
+      expect(template.render({
+        'ha_proxy' => {
+          'host_routes' => {
+            '*.example.com' => { 'servers' => ['10.0.0.3'], 'port' => '80' },
+            '*.internal.example.com' => { 'servers' => ['10.0.0.4'], 'port' => '80' }
+          }
+        }
+      }).lines.map { |line| line.split("\t").first }).to eq(['.internal.example.com', '.example.com'])
+    end
+  end
+
+  context 'when ha_proxy.host_routes is not provided' do
+    it 'is empty' do
+      expect(template.render({})).to be_a_blank_string
+    end
+  end
+end
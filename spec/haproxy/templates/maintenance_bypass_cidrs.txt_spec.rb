@@ -0,0 +1,22 @@
+# frozen_string_literal: true
+
+require 'rspec'
+
+describe 'config/maintenance_bypass_cidrs.txt' do
+  let(:template) { haproxy_job.template('config/maintenance_bypass_cidrs.txt') }
+
+  context 'when ha_proxy.maintenance.bypass_cidrs is provided' do
+    it 'lists the CIDRs' do
+      expect(template.render({
+        'ha_proxy' => {
+          'maintenance' => { 'bypass_cidrs' => ['10.0.0.0/8', '2001:db8::/32'] }
+        }
+      })).to eq(<<~EXPECTED)
+        # generated from maintenance_bypass_cidrs.txt.erb
+        # CIDRs whose requests are never answered with the maintenance page.
+        10.0.0.0/8
+        2001:db8::/32
+      EXPECTED
+    end
+  end
+end
@@ -0,0 +1,22 @@
+# frozen_string_literal: true
+
+require 'rspec'
+
+describe 'config/maintenance_hosts.txt' do
+  let(:template) { haproxy_job.template('config/maintenance_hosts.txt') }
+
+  context 'when ha_proxy.maintenance.hosts is provided' do
+    it 'lists the lowercased hosts' do
+      expect(template.render({
+        'ha_proxy' => {
+          'maintenance' => { 'hosts' => ['App.example.com', 'api.example.com'] }
+        }
+      })).to eq(<<~EXPECTED)
+        # generated from maintenance_hosts.txt.erb
+        # Hosts in maintenance mode. Entries can be added and removed at runtime with "add acl" and "del acl".
+        app.example.com
+        api.example.com
+      EXPECTED
+    end
+  end
+end
@@ -0,0 +1,21 @@
+# frozen_string_literal: true
+
+require 'rspec'
+
+describe 'config/maintenance_page' do
+  let(:template) { haproxy_job.template('config/maintenance_page') }
+
+  it 'has the default page' do
+    expect(template.render({})).to include('<h1>Down for maintenance</h1>')
+  end
+
+  context 'when ha_proxy.maintenance.page is provided' do
+    it 'has the configured page' do
+      expect(template.render({
+        'ha_proxy' => {
+          'maintenance' => { 'page' => '{"status":"maintenance"}' }
+        }
+      })).to eq('{"status":"maintenance"}')
+    end
+  end
+end
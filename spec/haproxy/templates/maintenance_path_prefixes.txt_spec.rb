@@ -0,0 +1,22 @@
+# frozen_string_literal: true
+
+require 'rspec'
+
+describe 'config/maintenance_path_prefixes.txt' do
+  let(:template) { haproxy_job.template('config/maintenance_path_prefixes.txt') }
+
+  context 'when ha_proxy.maintenance.path_prefixes is provided' do
+    it 'lists the path prefixes' do
+      expect(template.render({
+        'ha_proxy' => {
+          'maintenance' => { 'path_prefixes' => ['/api/', '/reports'] }
+        }
+      })).to eq(<<~EXPECTED)
+        # generated from maintenance_path_prefixes.txt.erb
+        # Path prefixes in maintenance mode. Entries can be added and removed at runtime with "add acl" and "del acl".
+        /api/
+        /reports
+      EXPECTED
+    end
+  end
+end
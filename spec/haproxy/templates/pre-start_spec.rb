@@ -33,4 +33,128 @@ describe 'bin/pre-start' do
       end
     end
   end
+
+  describe 'ha_proxy.geoip' do
+    it 'converts the provided databases to map files' do
+      pre_start = template.render(
+        {
+          'ha_proxy' => {
+            'geoip' => {
+              'country_database' => '/var/vcap/data/geoip/country.mmdb',
+              'asn_database' => '/var/vcap/data/geoip/asn.mmdb'
+            }
+          }
+        }
+      )
+      expect(pre_start).to include('/var/vcap/packages/haproxy-ctl/bin/haproxy-ctl geoip generate country /var/vcap/data/geoip/country.mmdb /var/vcap/jobs/haproxy/config/geoip/country.map')
+      expect(pre_start).to include('/var/vcap/packages/haproxy-ctl/bin/haproxy-ctl geoip generate asn /var/vcap/data/geoip/asn.mmdb /var/vcap/jobs/haproxy/config/geoip/asn.map')
+    end
+
+    it 'does not generate map files by default' do
+      expect(template.render({ 'ha_proxy' => {} })).not_to include('geoip generate')
+    end
+  end
+
+  describe 'ha_proxy.error_pages' do
+    it 'writes the pages with their placeholders replaced' do
+      pre_start = template.render(
+        {
+          'ha_proxy' => {
+            'error_pages' => [{
+              'statuses' => [502, 503],
+              'html' => "<h1>Error {{status}}</h1>\n<p style=\"width:100%\">{{request_id}}</p>\n",
+              'json' => '{"status": {{status}}, "request_id": "{{request_id}}"}'
+            }]
+          }
+        }
+      )
+      expect(pre_start).to include('mkdir -p /var/vcap/jobs/haproxy/errorpages')
+      expect(pre_start).to include(<<~EXPECTED)
+        cat > /var/vcap/jobs/haproxy/errorpages/0-503.html << 'ERROR_PAGE_EOF'
+        <h1>Error 503</h1>
+        <p style="width:100%%">%[var(txn.request_id)]</p>
+        ERROR_PAGE_EOF
+      EXPECTED
+      expect(pre_start).to include(<<~EXPECTED)
+        cat > /var/vcap/jobs/haproxy/errorpages/0-502.json << 'ERROR_PAGE_EOF'
+        {"status": 502, "request_id": "%[var(txn.request_id)]"}
+        ERROR_PAGE_EOF
+      EXPECTED
+    end
+
+    it 'does not end the page early at a line matching the delimiter' do
+      pre_start = template.render(
+        {
+          'ha_proxy' => {
+            'error_pages' => [{
+              'statuses' => [503],
+              'html' => "<pre>\nERROR_PAGE_EOF\n</pre>\n"
+            }]
+          }
+        }
+      )
+      expect(pre_start).to include(<<~EXPECTED)
+        cat > /var/vcap/jobs/haproxy/errorpages/0-503.html << 'ERROR_PAGE_EOF_'
+        <pre>
+        ERROR_PAGE_EOF
+        </pre>
+        ERROR_PAGE_EOF_
+      EXPECTED
+    end
+
+    it 'does not write pages by default' do
+      expect(template.render({ 'ha_proxy' => {} })).not_to include('errorpages')
+    end
+  end
+
+  describe 'ha_proxy.backend_discovery_file' do
+    it 'creates the directories of the endpoint files' do
+      pre_start = template.render(
+        {
+          'ha_proxy' => {
+            'backend_discovery_file' => '/var/vcap/data/haproxy-discovery/routers.json',
+            'routed_backend_servers' => {
+              '/images' => { 'port' => 8080, 'discovery_file' => '/var/vcap/data/haproxy-discovery/images.yml' }
+            }
+          }
+        }
+      )
+      expect(pre_start.scan('mkdir -p /var/vcap/data/haproxy-discovery').size).to eq(1)
+      expect(pre_start).to include('chown vcap:vcap /var/vcap/data/haproxy-discovery')
+      expect(pre_start).to include('chmod 1770 /var/vcap/data/haproxy-discovery')
+      expect(pre_start).not_to include('o+rwx')
+    end
+
+    it 'lets the members of ha_proxy.discovery_file_group write the endpoint files' do
+      pre_start = template.render(
+        {
+          'ha_proxy' => {
+            'backend_discovery_file' => '/var/vcap/store/haproxy-discovery/routers.json',
+            'discovery_file_group' => 'router-sync'
+          }
+        }
+      )
+      expect(pre_start).to include('chown vcap:router-sync /var/vcap/store/haproxy-discovery')
+    end
+  end
+
+  describe 'ha_proxy.runtime_api' do
+    it 'does not change the owner of the config directory by default' do
+      expect(template.render({ 'ha_proxy' => {} })).not_to include('chown')
+    end
+
+    context 'when enabled' do
+      it 'hands the CIDR files over to vcap' do
+        pre_start = template.render(
+          {
+            'ha_proxy' => {
+              'runtime_api' => { 'enabled' => true, 'username' => 'admin', 'password' => 'secret' }
+            }
+          }
+        )
+        expect(pre_start).to include('chown vcap:vcap /var/vcap/jobs/haproxy/config /var/vcap/jobs/haproxy/config/cidrs')
+        expect(pre_start).to include('chown -f vcap:vcap /var/vcap/jobs/haproxy/config/*_cidrs*.txt /var/vcap/jobs/haproxy/config/cidrs/* || true')
+      end
+    end
+  end
 end
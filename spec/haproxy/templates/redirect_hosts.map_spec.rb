@@ -0,0 +1,30 @@
+# frozen_string_literal: true
+
+require 'rspec'
+
+describe 'config/redirect_hosts.map' do
+  let(:template) { haproxy_job.template('config/redirect_hosts.map') }
+
+  context 'when ha_proxy.redirects is provided' do
+    it 'maps hosts to code, target and query preservation' do
+      expect(template.render({
+        'ha_proxy' => {
+          'redirects' => [
+            { 'host' => 'Old.Example.com', 'target' => 'https://new.example.com' },
+            { 'host' => 'legacy.example.com', 'target' => 'https://new.example.com', 'code' => 302, 'preserve_query' => false },
+            { 'path' => '/old', 'target' => '/new' }
+          ]
+        }
+      })).to eq(<<~EXPECTED)
+        old.example.com\t301|https://new.example.com|1
+        legacy.example.com\t302|https://new.example.com|0
+      EXPECTED
+    end
+  end
+
+  context 'when ha_proxy.redirects is not provided' do
+    it 'is empty' do
+      expect(template.render({})).to be_a_blank_string
+    end
+  end
+end
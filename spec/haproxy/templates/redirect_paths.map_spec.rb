@@ -0,0 +1,30 @@
+# frozen_string_literal: true
+
+require 'rspec'
+
+describe 'config/redirect_paths.map' do
+  let(:template) { haproxy_job.template('config/redirect_paths.map') }
+
+  context 'when ha_proxy.redirects is provided' do
+    it 'maps exact paths to code, target and query preservation' do
+      expect(template.render({
+        'ha_proxy' => {
+          'redirects' => [
+            { 'path' => '/pricing', 'target' => 'https://www.example.com/plans', 'code' => 307 },
+            { 'path' => '/caf%C3%A9', 'target' => '/cafe', 'preserve_query' => false },
+            { 'prefix' => '/docs/', 'target' => '/help/' }
+          ]
+        }
+      })).to eq(<<~EXPECTED)
+        /pricing\t307|https://www.example.com/plans|1
+        /caf%C3%A9\t301|/cafe|0
+      EXPECTED
+    end
+  end
+
+  context 'when ha_proxy.redirects is not provided' do
+    it 'is empty' do
+      expect(template.render({})).to be_a_blank_string
+    end
+  end
+end
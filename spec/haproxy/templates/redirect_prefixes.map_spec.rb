@@ -0,0 +1,26 @@
+# frozen_string_literal: true
+
+require 'rspec'
+
+describe 'config/redirect_prefixes.map' do
+  let(:template) { haproxy_job.template('config/redirect_prefixes.map') }
+
+  context 'when ha_proxy.redirects is provided' do
+    it 'maps path prefixes to code, target, query preservation and prefix length' do
+      expect(template.render({
+        'ha_proxy' => {
+          'redirects' => [
+            { 'prefix' => '/docs/v1/', 'target' => '/docs/v2/', 'code' => 308 },
+            { 'path' => '/old', 'target' => '/new' }
+          ]
+        }
+      })).to eq("/docs/v1/\t308|/docs/v2/|1|9\n")
+    end
+  end
+
+  context 'when ha_proxy.redirects is not provided' do
+    it 'is empty' do
+      expect(template.render({})).to be_a_blank_string
+    end
+  end
+end
@@ -0,0 +1,26 @@
+# frozen_string_literal: true
+
+require 'rspec'
+
+describe 'config/rewrite_prefixes.map' do
+  let(:template) { haproxy_job.template('config/rewrite_prefixes.map') }
+
+  context 'when ha_proxy.rewrites is provided' do
+    it 'maps path prefixes to target and prefix length' do
+      expect(template.render({
+        'ha_proxy' => {
+          'rewrites' => [
+            { 'prefix' => '/api/v1/', 'target' => '/v1/' },
+            { 'regex' => '^/static/[0-9a-f]+/(.*)$', 'target' => '/static/\1' }
+          ]
+        }
+      })).to eq("/api/v1/\t/v1/|8\n")
+    end
+  end
+
+  context 'when ha_proxy.rewrites is not provided' do
+    it 'is empty' do
+      expect(template.render({})).to be_a_blank_string
+    end
+  end
+end
@@ -25,6 +25,25 @@ describe 'config/trusted_domain_cidrs.txt' do
       end
     end
 
+    context 'when a space-separated list of cidrs starting with an IPv6 cidr is provided' do
+      it 'has the correct contents' do
+        expect(template.render({
+          'ha_proxy' => {
+            'trusted_domain_cidrs' => '2001:db8::/32 10.0.0.0/8'
+          }
+        })).to eq(<<~EXPECTED)
+          # generated from trusted_domain_cidrs.txt.erb
+
+          # BEGIN trusted_domain cidrs
+          2001:db8::/32
+          10.0.0.0/8
+
+          # END trusted_domain cidrs
+
+        EXPECTED
+      end
+    end
+
     context 'when a newline-separated, gzipped, base64-encoded list of cidrs is provided' do
       it 'has the correct contents' do
         expect(template.render({
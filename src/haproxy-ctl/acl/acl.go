@@ -0,0 +1,286 @@
+// Package acl changes the CIDR lists HAProxy loads with `acl ... -f <file>` at runtime,
+// and writes the same change to the backing file so that it survives reloads.
+package acl
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/cloudfoundry/haproxy-boshrelease/src/haproxy-ctl/runtimeapi"
+)
+
+// DefaultConfigDir is where the haproxy job renders its CIDR files.
+const DefaultConfigDir = "/var/vcap/jobs/haproxy/config"
+
+// builtinLists maps list names to the files rendered from the corresponding job properties.
+var builtinLists = map[string]string{
+	"whitelist":            "whitelist_cidrs.txt",
+	"blacklist":            "blacklist_cidrs.txt",
+	"blocklist_tcp":        "blocklist_cidrs_tcp.txt",
+	"expect_proxy":         "expect_proxy_cidrs.txt",
+	"trusted_domain":       "trusted_domain_cidrs.txt",
+	"rate_limit_exclusion": "rate_limit_exclusion_cidrs.txt",
+}
+
+// cidrsInFilePrefix prefixes the names of lists rendered from ha_proxy.cidrs_in_file.
+const cidrsInFilePrefix = "cidrs/"
+
+// ErrUnknownList is returned for list names which do not map to a CIDR file.
+var ErrUnknownList = errors.New("unknown list")
+
+// Manager adds and removes entries of CIDR lists.
+type Manager struct {
+	Runtime   runtimeapi.Runner
+	ConfigDir string
+
+	mu sync.Mutex
+}
+
+// NewManager returns a Manager for the CIDR files in configDir.
+func NewManager(runtime runtimeapi.Runner, configDir string) *Manager {
+	return &Manager{Runtime: runtime, ConfigDir: configDir}
+}
+
+// Lists returns the names of all known lists, including those from ha_proxy.cidrs_in_file.
+func (m *Manager) Lists() ([]string, error) {
+	var names []string
+	for name := range builtinLists {
+		names = append(names, name)
+	}
+
+	files, err := os.ReadDir(filepath.Join(m.ConfigDir, "cidrs"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	for _, file := range files {
+		if !file.IsDir() {
+			names = append(names, cidrsInFilePrefix+file.Name())
+		}
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+// Path returns the backing file of the list, which is also its identifier in the runtime API.
+func (m *Manager) Path(name string) (string, error) {
+	if file, ok := builtinLists[name]; ok {
+		return filepath.Join(m.ConfigDir, file), nil
+	}
+
+	if file, ok := strings.CutPrefix(name, cidrsInFilePrefix); ok && file != "" && file == filepath.Base(file) && file != "." && file != ".." {
+		return filepath.Join(m.ConfigDir, "cidrs", file), nil
+	}
+
+	return "", fmt.Errorf("%w %q", ErrUnknownList, name)
+}
+
+// Entries returns the entries persisted in the backing file of the list.
+func (m *Manager) Entries(name string) ([]string, error) {
+	path, err := m.Path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	lines, err := readLines(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []string
+	for _, line := range lines {
+		if entry := strings.TrimSpace(line); entry != "" && !strings.HasPrefix(entry, "#") {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// Add adds entries to the list in HAProxy and in its backing file. Entries already in the file are skipped.
+// When HAProxy rejects an entry, the entries added before it are still written to the file, so that they survive reloads.
+func (m *Manager) Add(name string, entries []string) error {
+	if err := validate(entries); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	path, err := m.Path(name)
+	if err != nil {
+		return err
+	}
+
+	existing, err := m.Entries(name)
+	if err != nil {
+		return err
+	}
+
+	lines, err := readLines(path)
+	if err != nil {
+		return err
+	}
+
+	var runtimeErr error
+	for _, entry := range entries {
+		if contains(existing, entry) {
+			continue
+		}
+
+		if out, err := m.Runtime.Run(fmt.Sprintf("add acl %s %s", path, entry)); err != nil {
+			runtimeErr = err
+			break
+		} else if out != "" {
+			runtimeErr = fmt.Errorf("adding %s to %s: %s", entry, name, out)
+			break
+		}
+
+		existing = append(existing, entry)
+		lines = append(lines, entry)
+	}
+
+	return persist(path, lines, runtimeErr)
+}
+
+// Delete removes entries from the list in HAProxy and from its backing file.
+// Like Add, it writes the entries removed before a failing one to the file.
+func (m *Manager) Delete(name string, entries []string) error {
+	if err := validate(entries); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	path, err := m.Path(name)
+	if err != nil {
+		return err
+	}
+
+	lines, err := readLines(path)
+	if err != nil {
+		return err
+	}
+
+	var runtimeErr error
+	for _, entry := range entries {
+		// An entry which is only in the file, e.g. after a failed earlier call, must still be removable.
+		if out, err := m.Runtime.Run(fmt.Sprintf("del acl %s %s", path, entry)); err != nil {
+			runtimeErr = err
+			break
+		} else if out != "" && out != "Key not found." {
+			runtimeErr = fmt.Errorf("deleting %s from %s: %s", entry, name, out)
+			break
+		}
+
+		var kept []string
+		for _, line := range lines {
+			if strings.TrimSpace(line) != entry {
+				kept = append(kept, line)
+			}
+		}
+		lines = kept
+	}
+
+	return persist(path, lines, runtimeErr)
+}
+
+// persist writes the lines applied in HAProxy so far, and returns the error which stopped the runtime changes.
+func persist(path string, lines []string, runtimeErr error) error {
+	if err := writeLines(path, lines); err != nil {
+		return errors.Join(runtimeErr, err)
+	}
+	return runtimeErr
+}
+
+func validate(entries []string) error {
+	if len(entries) == 0 {
+		return errors.New("no entries given")
+	}
+
+	for _, entry := range entries {
+		if _, _, err := net.ParseCIDR(entry); err == nil {
+			continue
+		}
+		if net.ParseIP(entry) != nil {
+			continue
+		}
+		return fmt.Errorf("invalid IP address or CIDR %q", entry)
+	}
+	return nil
+}
+
+func contains(entries []string, entry string) bool {
+	for _, e := range entries {
+		if e == entry {
+			return true
+		}
+	}
+	return false
+}
+
+func readLines(path string) ([]string, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(string(content), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines, nil
+}
+
+// writeLines replaces the file atomically so that a concurrent reload never reads a partial list.
+func writeLines(path string, lines []string) error {
+	mode := os.FileMode(0644)
+	uid, gid := -1, -1
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+		// HAProxy reads the file as vcap, e.g. root:vcap 0640 must not become root:root when run with sudo
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+			uid, gid = int(stat.Uid), int(stat.Gid)
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	content := strings.Join(lines, "\n")
+	if len(lines) > 0 {
+		content += "\n"
+	}
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if uid != -1 {
+		if err := tmp.Chown(uid, gid); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,228 @@
+package acl
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+)
+
+type fakeRunner struct {
+	commands  []string
+	responses map[string]string
+}
+
+func (f *fakeRunner) Run(command string) (string, error) {
+	f.commands = append(f.commands, command)
+	return f.responses[command], nil
+}
+
+func setup(t *testing.T) (*Manager, *fakeRunner, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "blocklist_cidrs_tcp.txt"), []byte("# generated from blocklist_cidrs_tcp.txt.erb\n10.0.0.0/8\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "cidrs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "cidrs", "sample_cidrs"), []byte("5.22.1.3\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	runner := &fakeRunner{responses: map[string]string{}}
+	return NewManager(runner, dir), runner, dir
+}
+
+func TestListsIncludesCidrsInFile(t *testing.T) {
+	manager, _, _ := setup(t)
+
+	lists, err := manager.Lists()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"blacklist", "blocklist_tcp", "cidrs/sample_cidrs", "whitelist"} {
+		if !contains(lists, name) {
+			t.Errorf("expected %q in %v", name, lists)
+		}
+	}
+}
+
+func TestPathRejectsUnknownLists(t *testing.T) {
+	manager, _, _ := setup(t)
+
+	for _, name := range []string{"unknown", "cidrs/", "cidrs/..", "cidrs/../haproxy.config"} {
+		if _, err := manager.Path(name); !errors.Is(err, ErrUnknownList) {
+			t.Errorf("expected ErrUnknownList for %q, got %v", name, err)
+		}
+	}
+}
+
+func TestAddUpdatesRuntimeAndFile(t *testing.T) {
+	manager, runner, dir := setup(t)
+	path := filepath.Join(dir, "blocklist_cidrs_tcp.txt")
+
+	if err := manager.Add("blocklist_tcp", []string{"10.0.0.0/8", "192.168.2.0/24", "2001:db8::1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	expectedCommands := []string{
+		"add acl " + path + " 192.168.2.0/24",
+		"add acl " + path + " 2001:db8::1",
+	}
+	if !reflect.DeepEqual(runner.commands, expectedCommands) {
+		t.Errorf("expected commands %v, got %v", expectedCommands, runner.commands)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedContent := "# generated from blocklist_cidrs_tcp.txt.erb\n10.0.0.0/8\n192.168.2.0/24\n2001:db8::1\n"
+	if string(content) != expectedContent {
+		t.Errorf("expected file content %q, got %q", expectedContent, content)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected file mode to be kept, got %v", info.Mode().Perm())
+	}
+}
+
+func TestAddLeavesFileUnchangedWhenRuntimeFails(t *testing.T) {
+	manager, runner, dir := setup(t)
+	path := filepath.Join(dir, "cidrs", "sample_cidrs")
+	runner.responses["add acl "+path+" 5.22.12.3"] = "Unknown ACL identifier. Please use #<id> or <file>."
+
+	if err := manager.Add("cidrs/sample_cidrs", []string{"5.22.12.3"}); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	entries, err := manager.Entries("cidrs/sample_cidrs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(entries, []string{"5.22.1.3"}) {
+		t.Errorf("expected file to be unchanged, got %v", entries)
+	}
+}
+
+func TestAddPersistsEntriesAddedBeforeRuntimeFails(t *testing.T) {
+	manager, runner, dir := setup(t)
+	path := filepath.Join(dir, "blocklist_cidrs_tcp.txt")
+	runner.responses["add acl "+path+" 192.168.3.0/24"] = "Out of memory error."
+
+	if err := manager.Add("blocklist_tcp", []string{"192.168.2.0/24", "192.168.3.0/24", "192.168.4.0/24"}); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	expectedCommands := []string{
+		"add acl " + path + " 192.168.2.0/24",
+		"add acl " + path + " 192.168.3.0/24",
+	}
+	if !reflect.DeepEqual(runner.commands, expectedCommands) {
+		t.Errorf("expected commands %v, got %v", expectedCommands, runner.commands)
+	}
+
+	entries, err := manager.Entries("blocklist_tcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(entries, []string{"10.0.0.0/8", "192.168.2.0/24"}) {
+		t.Errorf("expected the entry added in HAProxy to be persisted, got %v", entries)
+	}
+}
+
+func TestAddKeepsOwnerOfFile(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("changing the owner of a file needs root")
+	}
+	manager, _, dir := setup(t)
+	path := filepath.Join(dir, "blocklist_cidrs_tcp.txt")
+	if err := os.Chown(path, 0, 1000); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := manager.Add("blocklist_tcp", []string{"192.168.2.0/24"}); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stat := info.Sys().(*syscall.Stat_t)
+	if stat.Uid != 0 || stat.Gid != 1000 {
+		t.Errorf("expected owner 0:1000 to be kept, got %d:%d", stat.Uid, stat.Gid)
+	}
+}
+
+func TestAddRejectsInvalidEntries(t *testing.T) {
+	manager, runner, _ := setup(t)
+
+	for _, entries := range [][]string{nil, {"not-an-ip"}, {"10.0.0.0/8 #"}, {"10.0.0.0/33"}} {
+		if err := manager.Add("blocklist_tcp", entries); err == nil {
+			t.Errorf("expected an error for %v", entries)
+		}
+	}
+	if len(runner.commands) != 0 {
+		t.Errorf("expected no runtime commands, got %v", runner.commands)
+	}
+}
+
+func TestDeleteUpdatesRuntimeAndFile(t *testing.T) {
+	manager, runner, dir := setup(t)
+	path := filepath.Join(dir, "blocklist_cidrs_tcp.txt")
+	runner.responses["del acl "+path+" 172.16.0.0/12"] = "Key not found."
+
+	if err := manager.Delete("blocklist_tcp", []string{"10.0.0.0/8", "172.16.0.0/12"}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := manager.Entries("blocklist_tcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected no entries, got %v", entries)
+	}
+	if len(runner.commands) != 2 {
+		t.Errorf("expected two runtime commands, got %v", runner.commands)
+	}
+}
+
+func TestDeletePersistsEntriesDeletedBeforeRuntimeFails(t *testing.T) {
+	manager, runner, dir := setup(t)
+	path := filepath.Join(dir, "cidrs", "sample_cidrs")
+	if err := os.WriteFile(path, []byte("5.22.1.3\n5.22.1.4\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	runner.responses["del acl "+path+" 5.22.1.4"] = "Unknown ACL identifier. Please use #<id> or <file>."
+
+	if err := manager.Delete("cidrs/sample_cidrs", []string{"5.22.1.3", "5.22.1.4"}); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	entries, err := manager.Entries("cidrs/sample_cidrs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(entries, []string{"5.22.1.4"}) {
+		t.Errorf("expected the entry deleted in HAProxy to be removed from the file, got %v", entries)
+	}
+}
+
+func TestDeleteFailsOnRuntimeErrors(t *testing.T) {
+	manager, runner, dir := setup(t)
+	runner.responses["del acl "+filepath.Join(dir, "whitelist_cidrs.txt")+" 10.0.0.1"] = "Unknown ACL identifier. Please use #<id> or <file>."
+
+	if err := manager.Delete("whitelist", []string{"10.0.0.1"}); err == nil {
+		t.Fatal("expected an error")
+	}
+}
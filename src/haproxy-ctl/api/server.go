@@ -0,0 +1,116 @@
+// Package api exposes the runtime changes of haproxy-ctl over an authenticated HTTP endpoint.
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/cloudfoundry/haproxy-boshrelease/src/haproxy-ctl/acl"
+)
+
+// Credentials protect the endpoint with HTTP basic authentication.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+type entriesRequest struct {
+	Entries []string `json:"entries"`
+}
+
+type listResponse struct {
+	Name    string   `json:"name"`
+	Entries []string `json:"entries"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// NewHandler returns the handler for the /v1 API.
+func NewHandler(acls *acl.Manager, credentials Credentials) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /v1/acls", func(w http.ResponseWriter, r *http.Request) {
+		lists, err := acls.Lists()
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, lists)
+	})
+
+	mux.HandleFunc("GET /v1/acls/{name...}", func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		entries, err := acls.Entries(name)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, listResponse{Name: name, Entries: entries})
+	})
+
+	mux.HandleFunc("POST /v1/acls/{name...}", func(w http.ResponseWriter, r *http.Request) {
+		changeEntries(w, r, acls, acls.Add)
+	})
+
+	mux.HandleFunc("DELETE /v1/acls/{name...}", func(w http.ResponseWriter, r *http.Request) {
+		changeEntries(w, r, acls, acls.Delete)
+	})
+
+	return requireBasicAuth(mux, credentials)
+}
+
+func changeEntries(w http.ResponseWriter, r *http.Request, acls *acl.Manager, change func(string, []string) error) {
+	name := r.PathValue("name")
+
+	var req entriesRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body: " + err.Error()})
+		return
+	}
+
+	if err := change(name, req.Entries); err != nil {
+		writeError(w, err)
+		return
+	}
+	log.Printf("%s %s %v", r.Method, name, req.Entries)
+
+	entries, err := acls.Entries(name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, listResponse{Name: name, Entries: entries})
+}
+
+func requireBasicAuth(next http.Handler, credentials Credentials) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		usernameMatches := subtle.ConstantTimeCompare([]byte(username), []byte(credentials.Username)) == 1
+		passwordMatches := subtle.ConstantTimeCompare([]byte(password), []byte(credentials.Password)) == 1
+		if !ok || !usernameMatches || !passwordMatches {
+			w.Header().Set("WWW-Authenticate", `Basic realm="haproxy-ctl"`)
+			writeJSON(w, http.StatusUnauthorized, errorResponse{Error: "unauthorized"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusUnprocessableEntity
+	if errors.Is(err, acl.ErrUnknownList) {
+		status = http.StatusNotFound
+	}
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
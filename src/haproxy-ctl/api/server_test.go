@@ -0,0 +1,123 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cloudfoundry/haproxy-boshrelease/src/haproxy-ctl/acl"
+)
+
+type fakeRunner struct {
+	commands []string
+}
+
+func (f *fakeRunner) Run(command string) (string, error) {
+	f.commands = append(f.commands, command)
+	return "", nil
+}
+
+func setup(t *testing.T) (*httptest.Server, *fakeRunner) {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "blocklist_cidrs_tcp.txt"), []byte("10.0.0.0/8\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	runner := &fakeRunner{}
+	server := httptest.NewServer(NewHandler(acl.NewManager(runner, dir), Credentials{Username: "admin", Password: "secret"}))
+	t.Cleanup(server.Close)
+	return server, runner
+}
+
+func request(t *testing.T, server *httptest.Server, method, path, body, username, password string) (int, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth(username, password)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(content)
+}
+
+func TestRequiresCredentials(t *testing.T) {
+	server, _ := setup(t)
+
+	if status, _ := request(t, server, "GET", "/v1/acls", "", "admin", "wrong"); status != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d", status)
+	}
+}
+
+func TestShowsEntries(t *testing.T) {
+	server, _ := setup(t)
+
+	status, body := request(t, server, "GET", "/v1/acls/blocklist_tcp", "", "admin", "secret")
+	if status != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", status, body)
+	}
+
+	var list listResponse
+	if err := json.Unmarshal([]byte(body), &list); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(list, listResponse{Name: "blocklist_tcp", Entries: []string{"10.0.0.0/8"}}) {
+		t.Errorf("unexpected response %+v", list)
+	}
+}
+
+func TestAddsAndDeletesEntries(t *testing.T) {
+	server, runner := setup(t)
+
+	status, body := request(t, server, "POST", "/v1/acls/blocklist_tcp", `{"entries": ["192.168.2.0/24"]}`, "admin", "secret")
+	if status != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", status, body)
+	}
+	if !strings.Contains(body, "192.168.2.0/24") {
+		t.Errorf("expected new entry in response, got %s", body)
+	}
+
+	status, body = request(t, server, "DELETE", "/v1/acls/blocklist_tcp", `{"entries": ["192.168.2.0/24"]}`, "admin", "secret")
+	if status != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", status, body)
+	}
+	if strings.Contains(body, "192.168.2.0/24") {
+		t.Errorf("expected entry to be removed, got %s", body)
+	}
+
+	if len(runner.commands) != 2 {
+		t.Errorf("expected two runtime commands, got %v", runner.commands)
+	}
+}
+
+func TestRejectsInvalidRequests(t *testing.T) {
+	server, _ := setup(t)
+
+	if status, _ := request(t, server, "POST", "/v1/acls/unknown", `{"entries": ["10.0.0.1"]}`, "admin", "secret"); status != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown list, got %d", status)
+	}
+	if status, _ := request(t, server, "POST", "/v1/acls/blocklist_tcp", `{"entries": ["nope"]}`, "admin", "secret"); status != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for invalid entry, got %d", status)
+	}
+	if status, _ := request(t, server, "POST", "/v1/acls/blocklist_tcp", `not json`, "admin", "secret"); status != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid body, got %d", status)
+	}
+}
@@ -0,0 +1,109 @@
+// Package drain takes backend servers out of rotation gradually before they are removed, e.g. during
+// rolling deploys, and returns them afterwards.
+package drain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry/haproxy-boshrelease/src/haproxy-ctl/runtimeapi"
+)
+
+// ErrSessionsRemaining is returned by Drain when the server still has sessions after the drain timeout.
+var ErrSessionsRemaining = errors.New("sessions remaining")
+
+// Drainer lowers the weight of servers step by step, then moves them to DRAIN and MAINT.
+type Drainer struct {
+	Runtime runtimeapi.Runner
+	// Steps is the number of weight reductions spread over the ramp down.
+	Steps int
+	// Sleep waits between the steps, time.Sleep if nil.
+	Sleep func(time.Duration)
+}
+
+// ParseServer validates a server given as <backend>/<server>.
+func ParseServer(server string) (string, error) {
+	backend, name, found := strings.Cut(server, "/")
+	if !found || backend == "" || name == "" || strings.ContainsAny(server, " \t") {
+		return "", fmt.Errorf("invalid server %q, expected <backend>/<server>", server)
+	}
+	return server, nil
+}
+
+// Drain lowers the weight of server to 0% over rampDown, moves it to DRAIN and waits up to timeout
+// for its remaining sessions to finish, before putting it into MAINT. Sessions which persist on the
+// server, e.g. by cookie, keep reaching it until then.
+func (d *Drainer) Drain(server string, rampDown, timeout time.Duration) error {
+	steps := max(d.Steps, 1)
+	for step := steps - 1; step >= 0; step-- {
+		if err := d.run(fmt.Sprintf("set server %s weight %d%%", server, 100*step/steps)); err != nil {
+			return err
+		}
+		if step > 0 {
+			d.sleep(rampDown / time.Duration(steps))
+		}
+	}
+	if err := d.run(fmt.Sprintf("set server %s state drain", server)); err != nil {
+		return err
+	}
+
+	for waited := time.Duration(0); ; waited += time.Second {
+		sessions, err := d.sessions(server)
+		if err != nil {
+			return err
+		}
+		if sessions == "0" {
+			break
+		}
+		if waited >= timeout {
+			return fmt.Errorf("draining %s: %w: %s", server, ErrSessionsRemaining, sessions)
+		}
+		d.sleep(time.Second)
+	}
+	return d.run(fmt.Sprintf("set server %s state maint", server))
+}
+
+// Ready returns server into rotation with its configured weight. HAProxy ramps its traffic up
+// over the slowstart duration of the server, if any.
+func (d *Drainer) Ready(server string) error {
+	if err := d.run(fmt.Sprintf("set server %s weight 100%%", server)); err != nil {
+		return err
+	}
+	return d.run(fmt.Sprintf("set server %s state ready", server))
+}
+
+// sessions returns the current number of sessions of server as reported by `show stat`.
+func (d *Drainer) sessions(server string) (string, error) {
+	stats, err := runtimeapi.ShowStat(d.Runtime)
+	if err != nil {
+		return "", err
+	}
+	for _, stat := range stats {
+		if stat["pxname"]+"/"+stat["svname"] == server && stat.IsServer() {
+			return stat["scur"], nil
+		}
+	}
+	return "", fmt.Errorf("no such server %s", server)
+}
+
+// run sends command and treats any output as an error, as HAProxy answers successful `set server` commands with nothing.
+func (d *Drainer) run(command string) error {
+	out, err := d.Runtime.Run(command)
+	if err != nil {
+		return err
+	}
+	if out != "" {
+		return fmt.Errorf("%s: %s", command, out)
+	}
+	return nil
+}
+
+func (d *Drainer) sleep(duration time.Duration) {
+	if d.Sleep != nil {
+		d.Sleep(duration)
+		return
+	}
+	time.Sleep(duration)
+}
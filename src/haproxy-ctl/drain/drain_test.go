@@ -0,0 +1,108 @@
+package drain
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type fakeRunner struct {
+	commands []string
+	// stats are returned by consecutive `show stat` commands, the last one repeatedly
+	stats     []string
+	responses map[string]string
+}
+
+func (f *fakeRunner) Run(command string) (string, error) {
+	f.commands = append(f.commands, command)
+	if command == "show stat" {
+		stat := f.stats[0]
+		if len(f.stats) > 1 {
+			f.stats = f.stats[1:]
+		}
+		return stat, nil
+	}
+	return f.responses[command], nil
+}
+
+func statWithSessions(sessions string) string {
+	return "# pxname,svname,status,scur,\n" +
+		"api,FRONTEND,OPEN,9,\n" +
+		"api,node0,DRAIN," + sessions + ",\n" +
+		"api,BACKEND,UP,9,\n"
+}
+
+func TestDrainRampsDownTheWeightBeforeMaintenance(t *testing.T) {
+	runner := &fakeRunner{stats: []string{statWithSessions("2"), statWithSessions("0")}}
+	var sleeps []time.Duration
+	drainer := &Drainer{Runtime: runner, Steps: 4, Sleep: func(d time.Duration) { sleeps = append(sleeps, d) }}
+
+	if err := drainer.Drain("api/node0", 8*time.Second, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"set server api/node0 weight 75%",
+		"set server api/node0 weight 50%",
+		"set server api/node0 weight 25%",
+		"set server api/node0 weight 0%",
+		"set server api/node0 state drain",
+		"show stat",
+		"show stat",
+		"set server api/node0 state maint",
+	}
+	if !reflect.DeepEqual(runner.commands, expected) {
+		t.Errorf("unexpected commands %v", runner.commands)
+	}
+	if !reflect.DeepEqual(sleeps, []time.Duration{2 * time.Second, 2 * time.Second, 2 * time.Second, time.Second}) {
+		t.Errorf("unexpected sleeps %v", sleeps)
+	}
+}
+
+func TestDrainFailsWhenSessionsRemain(t *testing.T) {
+	runner := &fakeRunner{stats: []string{statWithSessions("3")}}
+	drainer := &Drainer{Runtime: runner, Steps: 1, Sleep: func(time.Duration) {}}
+
+	err := drainer.Drain("api/node0", 0, 5*time.Second)
+	if !errors.Is(err, ErrSessionsRemaining) {
+		t.Fatalf("expected ErrSessionsRemaining, got %v", err)
+	}
+	if last := runner.commands[len(runner.commands)-1]; last != "show stat" {
+		t.Errorf("expected the server to stay in DRAIN, last command was %q", last)
+	}
+}
+
+func TestDrainFailsForUnknownServers(t *testing.T) {
+	runner := &fakeRunner{responses: map[string]string{"set server api/node9 weight 0%": "No such server."}}
+	drainer := &Drainer{Runtime: runner, Steps: 1}
+
+	if err := drainer.Drain("api/node9", 0, time.Minute); err == nil {
+		t.Error("expected an error")
+	}
+	if len(runner.commands) != 1 {
+		t.Errorf("expected no further commands, got %v", runner.commands)
+	}
+}
+
+func TestReadyRestoresTheWeight(t *testing.T) {
+	runner := &fakeRunner{}
+	drainer := &Drainer{Runtime: runner}
+
+	if err := drainer.Ready("api/node0"); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(runner.commands, []string{"set server api/node0 weight 100%", "set server api/node0 state ready"}) {
+		t.Errorf("unexpected commands %v", runner.commands)
+	}
+}
+
+func TestParseServer(t *testing.T) {
+	for _, server := range []string{"api", "/node0", "api/", "api/node0 state maint"} {
+		if _, err := ParseServer(server); err == nil {
+			t.Errorf("expected an error for %q", server)
+		}
+	}
+	if server, err := ParseServer("api/node0"); err != nil || server != "api/node0" {
+		t.Errorf("unexpected result %q, %v", server, err)
+	}
+}
@@ -0,0 +1,62 @@
+package filediscovery
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// marker starts the comment with which the haproxy job marks a backend whose servers are read from a file,
+// followed by the path of the file and the default port of the servers, e.g. `# file-discovery /var/vcap/data/routers.json 80`.
+const marker = "# file-discovery "
+
+// Target is a backend whose servers are kept in sync with an endpoints file.
+type Target struct {
+	Backend string
+	File    string
+	Port    int
+}
+
+// ParseConfig returns the backends of the HAProxy config at path which are marked for file discovery.
+func ParseConfig(path string) ([]Target, error) {
+	config, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer config.Close()
+
+	var targets []Target
+	backend := ""
+	scanner := bufio.NewScanner(config)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// Sections start at the beginning of a line, their keywords are indented
+		if line != "" && line[0] != ' ' && line[0] != '\t' && line[0] != '#' {
+			fields := strings.Fields(line)
+			backend = ""
+			if len(fields) == 2 && fields[0] == "backend" {
+				backend = fields[1]
+			}
+			continue
+		}
+		discovery, found := strings.CutPrefix(strings.TrimSpace(line), marker)
+		if !found || backend == "" {
+			continue
+		}
+		fields := strings.Fields(discovery)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid file discovery of backend %s in %s: %q", backend, path, line)
+		}
+		port, err := strconv.Atoi(fields[1])
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid file discovery of backend %s in %s: port %q is out of range", backend, path, fields[1])
+		}
+		targets = append(targets, Target{Backend: backend, File: fields[0], Port: port})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return targets, nil
+}
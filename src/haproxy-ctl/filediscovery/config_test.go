@@ -0,0 +1,50 @@
+package filediscovery
+
+import (
+	"reflect"
+	"testing"
+)
+
+const config = `global
+    maxconn 64000
+
+backend http-routers-http1
+    mode http
+    # file-discovery /var/vcap/data/haproxy-discovery/routers.json 80
+    server node0 0.0.0.0:80 disabled check inter 1000
+
+# Routed Backends {{{
+backend http-routed-backend-9c1bb7
+    mode http
+    # file-discovery /var/vcap/data/images/endpoints.yml 8443
+    server node0 0.0.0.0:8443 disabled check inter 1000
+
+backend http-routed-backend-702acf
+    mode http
+    server node0 10.0.0.2:8080 check inter 1000
+
+frontend http-in
+    # file-discovery /var/vcap/data/ignored.json 80
+`
+
+func TestParseConfigFindsMarkedBackends(t *testing.T) {
+	targets, err := ParseConfig(writeFile(t, "haproxy.config", config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Target{
+		{Backend: "http-routers-http1", File: "/var/vcap/data/haproxy-discovery/routers.json", Port: 80},
+		{Backend: "http-routed-backend-9c1bb7", File: "/var/vcap/data/images/endpoints.yml", Port: 8443},
+	}
+	if !reflect.DeepEqual(targets, expected) {
+		t.Errorf("unexpected targets %v", targets)
+	}
+}
+
+func TestParseConfigRejectsInvalidMarkers(t *testing.T) {
+	for _, marker := range []string{"# file-discovery /var/vcap/data/routers.json", "# file-discovery /var/vcap/data/routers.json http"} {
+		if _, err := ParseConfig(writeFile(t, "haproxy.config", "backend api\n    "+marker+"\n")); err == nil {
+			t.Errorf("%q: expected an error", marker)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// Package filediscovery keeps the servers of backends in sync with a file of endpoints, which is written
+// by tooling outside of BOSH. The servers are changed through the runtime API, without reloading HAProxy.
+package filediscovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Endpoint is a server of a backend. A Port of 0 stands for the port configured for the backend.
+type Endpoint struct {
+	Address string `json:"address" yaml:"address"`
+	Port    int    `json:"port" yaml:"port"`
+}
+
+func (e Endpoint) String() string {
+	if e.Port == 0 {
+		return e.Address
+	}
+	return net.JoinHostPort(e.Address, strconv.Itoa(e.Port))
+}
+
+// file is the format of the endpoints file, e.g. {"servers": [{"address": "10.0.0.1", "port": 8080}]}.
+// Servers is nil if the key is missing or has no value, e.g. in an empty or truncated file.
+type file struct {
+	Servers []Endpoint `json:"servers" yaml:"servers"`
+}
+
+// Load reads the endpoints from a JSON file, or a YAML file if its extension is .yml or .yaml.
+// It rejects the whole file if any endpoint is invalid, so that a partially written file does not remove servers.
+func Load(path string) ([]Endpoint, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed file
+	switch filepath.Ext(path) {
+	case ".yml", ".yaml":
+		err = yaml.UnmarshalStrict(content, &parsed)
+	default:
+		err = json.Unmarshal(content, &parsed)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if parsed.Servers == nil {
+		return nil, fmt.Errorf("parsing %s: the list of servers is missing, an empty list removes all servers", path)
+	}
+
+	seen := map[string]bool{}
+	for _, endpoint := range parsed.Servers {
+		if net.ParseIP(endpoint.Address) == nil {
+			return nil, fmt.Errorf("invalid endpoint in %s: %q is not an IP address", path, endpoint.Address)
+		}
+		if endpoint.Port < 0 || endpoint.Port > 65535 {
+			return nil, fmt.Errorf("invalid endpoint in %s: port %d of %s is out of range", path, endpoint.Port, endpoint.Address)
+		}
+		if seen[endpoint.String()] {
+			return nil, fmt.Errorf("invalid endpoint in %s: %s is listed twice", path, endpoint)
+		}
+		seen[endpoint.String()] = true
+	}
+	return parsed.Servers, nil
+}
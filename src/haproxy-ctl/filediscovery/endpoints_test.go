@@ -0,0 +1,76 @@
+package filediscovery
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadReadsJSONAndYAML(t *testing.T) {
+	expected := []Endpoint{{Address: "10.0.0.1", Port: 8080}, {Address: "2001:db8::1"}}
+	files := map[string]string{
+		"endpoints.json": `{"servers": [{"address": "10.0.0.1", "port": 8080}, {"address": "2001:db8::1"}]}`,
+		"endpoints.yml":  "servers:\n- address: 10.0.0.1\n  port: 8080\n- address: \"2001:db8::1\"\n",
+	}
+	for name, content := range files {
+		endpoints, err := Load(writeFile(t, name, content))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(endpoints, expected) {
+			t.Errorf("%s: unexpected endpoints %v", name, endpoints)
+		}
+	}
+}
+
+func TestLoadRejectsInvalidEndpoints(t *testing.T) {
+	files := map[string]string{
+		"hostname.json":  `{"servers": [{"address": "router.example.com"}]}`,
+		"port.json":      `{"servers": [{"address": "10.0.0.1", "port": 70000}]}`,
+		"duplicate.json": `{"servers": [{"address": "10.0.0.1", "port": 80}, {"address": "10.0.0.1", "port": 80}]}`,
+		"truncated.json": `{"servers": [{"address": "10.0.0.1"`,
+		"unknown.yaml":   "servers:\n- adress: 10.0.0.1\n",
+	}
+	for name, content := range files {
+		if _, err := Load(writeFile(t, name, content)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestLoadRejectsFilesWithoutServers(t *testing.T) {
+	files := map[string]string{
+		"empty.yml":      "",
+		"bare.yml":       "servers:\n",
+		"empty.json":     "{}",
+		"null.json":      `{"servers": null}`,
+		"whitespace.yml": "\n",
+	}
+	for name, content := range files {
+		if _, err := Load(writeFile(t, name, content)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestLoadAcceptsAnEmptyList(t *testing.T) {
+	for name, content := range map[string]string{"empty.json": `{"servers": []}`, "empty.yml": "servers: []\n"} {
+		endpoints, err := Load(writeFile(t, name, content))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(endpoints) != 0 {
+			t.Errorf("%s: unexpected endpoints %v", name, endpoints)
+		}
+	}
+}
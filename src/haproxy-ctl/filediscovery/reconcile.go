@@ -0,0 +1,165 @@
+package filediscovery
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/cloudfoundry/haproxy-boshrelease/src/haproxy-ctl/runtimeapi"
+)
+
+// Reconciler moves the servers of backends to the endpoints of a file. Each backend has a fixed number of
+// server slots, endpoints are added to slots in MAINT and removed by putting their slot into MAINT.
+// A slot in MAINT whose endpoint is still in the file was put there by someone else, e.g. `haproxy-ctl drain`,
+// and is left alone unless the Reconciler put it there itself.
+type Reconciler struct {
+	Runtime runtimeapi.Runner
+	// released holds the slots the Reconciler put into MAINT, as <backend>/<server>.
+	released map[string]bool
+}
+
+// slot is a server of a backend as reported by `show stat`.
+type slot struct {
+	name    string
+	address string
+	port    string
+	maint   bool
+}
+
+// matches reports whether the slot serves endpoint.
+func (s slot) matches(endpoint Endpoint) bool {
+	if net.ParseIP(s.address) == nil || !net.ParseIP(s.address).Equal(net.ParseIP(endpoint.Address)) {
+		return false
+	}
+	return s.port == strconv.Itoa(endpoint.Port)
+}
+
+// Reconcile changes the servers of backend to endpoints and returns the changes it made, which are drift
+// between the file and HAProxy, e.g. after the file changed or HAProxy reloaded. Endpoints without a port
+// use port. Endpoints which do not fit into the free slots are reported as an error after all other changes were made.
+func (r *Reconciler) Reconcile(backend string, port int, endpoints []Endpoint) ([]string, error) {
+	slots, err := r.slots(backend)
+	if err != nil {
+		return nil, err
+	}
+	if r.released == nil {
+		r.released = map[string]bool{}
+	}
+	withPorts := make([]Endpoint, len(endpoints))
+	for i, endpoint := range endpoints {
+		if endpoint.Port == 0 {
+			endpoint.Port = port
+		}
+		withPorts[i] = endpoint
+	}
+	endpoints = withPorts
+
+	var changes []string
+	served := map[string]bool{}
+	var free []slot
+	for _, s := range slots {
+		server := backend + "/" + s.name
+		if s.maint && r.released[server] {
+			free = append(free, s)
+			continue
+		}
+		if !s.maint {
+			delete(r.released, server)
+		}
+		wanted := false
+		for _, endpoint := range endpoints {
+			if !served[endpoint.String()] && s.matches(endpoint) {
+				served[endpoint.String()] = true
+				wanted = true
+				break
+			}
+		}
+		if wanted {
+			continue
+		}
+		if s.maint {
+			free = append(free, s)
+			continue
+		}
+		if err := r.run(fmt.Sprintf("set server %s state maint", server)); err != nil {
+			return changes, err
+		}
+		r.released[server] = true
+		changes = append(changes, fmt.Sprintf("removed %s from %s", net.JoinHostPort(s.address, s.port), server))
+		free = append(free, s)
+	}
+
+	var missing []string
+	for _, endpoint := range endpoints {
+		if served[endpoint.String()] {
+			continue
+		}
+		if len(free) == 0 {
+			missing = append(missing, endpoint.String())
+			continue
+		}
+		s := free[0]
+		free = free[1:]
+		command := fmt.Sprintf("set server %s/%s addr %s port %d", backend, s.name, endpoint.Address, endpoint.Port)
+		// HAProxy describes the change in its answer, e.g. "IP changed from '0.0.0.0' to '10.0.0.1' by 'stats socket command'"
+		if out, err := r.Runtime.Run(command); err != nil {
+			return changes, err
+		} else if !strings.Contains(out, "changed") && !strings.Contains(out, "no need to change") {
+			return changes, fmt.Errorf("%s: %s", command, out)
+		}
+		if err := r.run(fmt.Sprintf("set server %s/%s state ready", backend, s.name)); err != nil {
+			return changes, err
+		}
+		delete(r.released, backend+"/"+s.name)
+		changes = append(changes, fmt.Sprintf("added %s to %s/%s", endpoint, backend, s.name))
+	}
+
+	if len(missing) > 0 {
+		return changes, fmt.Errorf("no free server slots in %s for %s, increase the number of slots", backend, strings.Join(missing, ", "))
+	}
+	return changes, nil
+}
+
+// slots returns the servers of backend.
+func (r *Reconciler) slots(backend string) ([]slot, error) {
+	stats, err := runtimeapi.ShowStat(r.Runtime)
+	if err != nil {
+		return nil, err
+	}
+
+	var slots []slot
+	for _, stat := range stats {
+		if stat["pxname"] != backend || !stat.IsServer() {
+			continue
+		}
+		address, port, err := net.SplitHostPort(stat["addr"])
+		if err != nil {
+			// IPv6 addresses are not enclosed in brackets
+			separator := strings.LastIndex(stat["addr"], ":")
+			address, port = stat["addr"][:max(separator, 0)], stat["addr"][separator+1:]
+		}
+		slots = append(slots, slot{
+			name:    stat["svname"],
+			address: address,
+			port:    port,
+			maint:   strings.HasPrefix(stat["status"], "MAINT"),
+		})
+	}
+	if len(slots) == 0 {
+		return nil, fmt.Errorf("no servers in backend %s", backend)
+	}
+	return slots, nil
+}
+
+// run sends command and treats any output as an error.
+func (r *Reconciler) run(command string) error {
+	out, err := r.Runtime.Run(command)
+	if err != nil {
+		return err
+	}
+	if out != "" {
+		return fmt.Errorf("%s: %s", command, out)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package filediscovery
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type fakeRunner struct {
+	commands []string
+	stat     string
+}
+
+func (f *fakeRunner) Run(command string) (string, error) {
+	f.commands = append(f.commands, command)
+	switch {
+	case command == "show stat":
+		return f.stat, nil
+	case strings.Contains(command, " addr "):
+		return "IP changed from '0.0.0.0' to '10.0.0.9' by 'stats socket command'", nil
+	}
+	return "", nil
+}
+
+const stat = "# pxname,svname,status,addr,\n" +
+	"api,FRONTEND,OPEN,,\n" +
+	"api,node1,UP,10.0.0.1:8080,\n" +
+	"api,node2,DOWN,10.0.0.2:8080,\n" +
+	"api,node3,MAINT,0.0.0.0:8080,\n" +
+	"api,BACKEND,UP,,\n" +
+	"other,node1,UP,10.0.0.5:8080,\n"
+
+func TestReconcileReplacesRemovedEndpoints(t *testing.T) {
+	runner := &fakeRunner{stat: stat}
+	reconciler := &Reconciler{Runtime: runner}
+
+	changes, err := reconciler.Reconcile("api", 8080, []Endpoint{{Address: "10.0.0.1"}, {Address: "10.0.0.3", Port: 9090}, {Address: "10.0.0.4"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedCommands := []string{
+		"show stat",
+		"set server api/node2 state maint",
+		"set server api/node2 addr 10.0.0.3 port 9090",
+		"set server api/node2 state ready",
+		"set server api/node3 addr 10.0.0.4 port 8080",
+		"set server api/node3 state ready",
+	}
+	if !reflect.DeepEqual(runner.commands, expectedCommands) {
+		t.Errorf("unexpected commands %v", runner.commands)
+	}
+	expectedChanges := []string{
+		"removed 10.0.0.2:8080 from api/node2",
+		"added 10.0.0.3:9090 to api/node2",
+		"added 10.0.0.4:8080 to api/node3",
+	}
+	if !reflect.DeepEqual(changes, expectedChanges) {
+		t.Errorf("unexpected changes %v", changes)
+	}
+}
+
+func TestReconcileLeavesMatchingServers(t *testing.T) {
+	runner := &fakeRunner{stat: stat}
+	reconciler := &Reconciler{Runtime: runner}
+
+	changes, err := reconciler.Reconcile("api", 8080, []Endpoint{{Address: "10.0.0.1", Port: 8080}, {Address: "10.0.0.2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 || len(runner.commands) != 1 {
+		t.Errorf("expected no changes, got %v and commands %v", changes, runner.commands)
+	}
+}
+
+func TestReconcileReportsEndpointsWithoutSlots(t *testing.T) {
+	runner := &fakeRunner{stat: stat}
+	reconciler := &Reconciler{Runtime: runner}
+
+	endpoints := []Endpoint{{Address: "10.0.0.1"}, {Address: "10.0.0.2"}, {Address: "10.0.0.3"}, {Address: "10.0.0.4"}}
+	changes, err := reconciler.Reconcile("api", 8080, endpoints)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if !reflect.DeepEqual(changes, []string{"added 10.0.0.3:8080 to api/node3"}) {
+		t.Errorf("unexpected changes %v", changes)
+	}
+}
+
+func TestReconcileFailsForUnknownBackends(t *testing.T) {
+	reconciler := &Reconciler{Runtime: &fakeRunner{stat: stat}}
+
+	if _, err := reconciler.Reconcile("missing", 8080, nil); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestReconcileResetsThePortOfReusedSlots(t *testing.T) {
+	runner := &fakeRunner{stat: "# pxname,svname,status,addr,\n" +
+		"api,node1,MAINT,10.0.0.1:9090,\n"}
+	reconciler := &Reconciler{Runtime: runner}
+
+	if _, err := reconciler.Reconcile("api", 8080, []Endpoint{{Address: "10.0.0.2"}}); err != nil {
+		t.Fatal(err)
+	}
+	if runner.commands[1] != "set server api/node1 addr 10.0.0.2 port 8080" {
+		t.Errorf("unexpected commands %v", runner.commands)
+	}
+}
+
+func TestReconcileLeavesServersPutIntoMaintBySomeoneElse(t *testing.T) {
+	runner := &fakeRunner{stat: "# pxname,svname,status,addr,\n" +
+		"api,node1,UP,10.0.0.1:8080,\n" +
+		"api,node2,MAINT,10.0.0.2:8080,\n" +
+		"api,node3,MAINT,0.0.0.0:8080,\n"}
+	reconciler := &Reconciler{Runtime: runner}
+
+	changes, err := reconciler.Reconcile("api", 8080, []Endpoint{{Address: "10.0.0.1"}, {Address: "10.0.0.2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// node2 was drained, its endpoint neither returns into rotation nor moves to node3
+	if len(changes) != 0 || len(runner.commands) != 1 {
+		t.Errorf("expected no changes, got %v and commands %v", changes, runner.commands)
+	}
+}
+
+func TestReconcileReusesServersItPutIntoMaint(t *testing.T) {
+	runner := &fakeRunner{stat: "# pxname,svname,status,addr,\n" +
+		"api,node1,UP,10.0.0.1:8080,\n"}
+	reconciler := &Reconciler{Runtime: runner}
+
+	if _, err := reconciler.Reconcile("api", 8080, []Endpoint{}); err != nil {
+		t.Fatal(err)
+	}
+	runner.commands = nil
+	runner.stat = "# pxname,svname,status,addr,\n" +
+		"api,node1,MAINT,10.0.0.1:8080,\n"
+
+	changes, err := reconciler.Reconcile("api", 8080, []Endpoint{{Address: "10.0.0.1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedCommands := []string{
+		"show stat",
+		"set server api/node1 addr 10.0.0.1 port 8080",
+		"set server api/node1 state ready",
+	}
+	if !reflect.DeepEqual(runner.commands, expectedCommands) {
+		t.Errorf("unexpected commands %v", runner.commands)
+	}
+	if !reflect.DeepEqual(changes, []string{"added 10.0.0.1:8080 to api/node1"}) {
+		t.Errorf("unexpected changes %v", changes)
+	}
+}
@@ -0,0 +1,123 @@
+package geoip
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Field selects the value written to a map file for each network.
+type Field string
+
+const (
+	// Country writes the ISO 3166-1 country code, as found in country and city databases.
+	Country Field = "country"
+	// ASN writes the autonomous system number, as found in ASN databases.
+	ASN Field = "asn"
+)
+
+// WriteMap writes a map file with one `<network> <value>` line for every network of the database
+// which has a value for field, and returns the number of lines written.
+func WriteMap(r *Reader, field Field, w io.Writer) (int, error) {
+	var extract func(interface{}) string
+	switch field {
+	case Country:
+		extract = countryCode
+	case ASN:
+		extract = asn
+	default:
+		return 0, fmt.Errorf("unknown field %q, known fields: %q, %q", field, Country, ASN)
+	}
+
+	// many networks share the same record
+	values := map[uint]string{}
+	buffered := bufio.NewWriter(w)
+	lines := 0
+
+	err := r.Networks(func(network *net.IPNet, offset uint) error {
+		value, ok := values[offset]
+		if !ok {
+			record, err := r.Record(offset)
+			if err != nil {
+				return err
+			}
+			value = extract(record)
+			values[offset] = value
+		}
+
+		if value == "" {
+			return nil
+		}
+		lines++
+		_, err := fmt.Fprintf(buffered, "%s %s\n", network, value)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return lines, buffered.Flush()
+}
+
+// GenerateMapFile converts the database at databasePath into the map file at mapPath.
+// The map file is replaced atomically so that a concurrent reload never reads a partial map.
+func GenerateMapFile(databasePath string, field Field, mapPath string) (int, error) {
+	r, err := Open(databasePath)
+	if err != nil {
+		return 0, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(mapPath), "."+filepath.Base(mapPath)+".*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	lines, err := WriteMap(r, field, tmp)
+	if err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+
+	return lines, os.Rename(tmp.Name(), mapPath)
+}
+
+func countryCode(record interface{}) string {
+	for _, key := range []string{"country", "registered_country"} {
+		if code := lookup(record, key, "iso_code"); code != nil {
+			if s, ok := code.(string); ok {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+func asn(record interface{}) string {
+	if number, ok := lookup(record, "autonomous_system_number").(uint64); ok {
+		return strconv.FormatUint(number, 10)
+	}
+	return ""
+}
+
+func lookup(value interface{}, keys ...string) interface{} {
+	for _, key := range keys {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = m[key]
+	}
+	return value
+}
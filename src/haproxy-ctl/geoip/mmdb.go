@@ -0,0 +1,351 @@
+// Package geoip converts MaxMind DB (MMDB) files into map files HAProxy can load with map_ip.
+//
+// Only the subset of the format needed to walk all networks of a database and decode
+// their records is implemented, see https://maxmind.github.io/MaxMind-DB/.
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"os"
+)
+
+var metadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// Reader reads a MaxMind DB held in memory.
+type Reader struct {
+	buf        []byte
+	data       decoder
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+}
+
+// Open reads the MaxMind DB at path.
+func Open(path string) (*Reader, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewReader(buf)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return r, nil
+}
+
+// NewReader parses the metadata of the MaxMind DB in buf.
+func NewReader(buf []byte) (*Reader, error) {
+	metadataStart := bytes.LastIndex(buf, metadataMarker)
+	if metadataStart == -1 {
+		return nil, errors.New("invalid MaxMind DB: metadata marker not found")
+	}
+
+	metadataDecoder := decoder{buf: buf[metadataStart+len(metadataMarker):]}
+	value, _, err := metadataDecoder.decode(0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MaxMind DB metadata: %w", err)
+	}
+	metadata, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid MaxMind DB metadata: not a map")
+	}
+
+	r := &Reader{buf: buf}
+	for key, field := range map[string]*uint{"node_count": &r.nodeCount, "record_size": &r.recordSize, "ip_version": &r.ipVersion} {
+		n, ok := metadata[key].(uint64)
+		if !ok {
+			return nil, fmt.Errorf("invalid MaxMind DB metadata: missing %s", key)
+		}
+		*field = uint(n)
+	}
+
+	if r.recordSize != 24 && r.recordSize != 28 && r.recordSize != 32 {
+		return nil, fmt.Errorf("invalid MaxMind DB: unsupported record size %d", r.recordSize)
+	}
+	if r.ipVersion != 4 && r.ipVersion != 6 {
+		return nil, fmt.Errorf("invalid MaxMind DB: unsupported ip version %d", r.ipVersion)
+	}
+
+	treeSize := r.nodeCount * r.recordSize / 4
+	dataStart := treeSize + 16
+	if dataStart > uint(metadataStart) {
+		return nil, errors.New("invalid MaxMind DB: search tree exceeds file size")
+	}
+	r.data = decoder{buf: buf[dataStart:metadataStart]}
+
+	return r, nil
+}
+
+// Networks calls fn for every network in the database which has a record, passing the offset of the record.
+// Records of networks in IPv4 address space are returned as IPv4 networks. The IPv4 aliases ::ffff:0:0/96
+// and 2002::/16 of IPv6 databases are skipped, as they would repeat every IPv4 network.
+func (r *Reader) Networks(fn func(network *net.IPNet, offset uint) error) error {
+	bitCount := uint(32)
+	if r.ipVersion == 6 {
+		bitCount = 128
+	}
+
+	type entry struct {
+		node  uint
+		ip    net.IP
+		depth uint
+	}
+	stack := []entry{{node: 0, ip: make(net.IP, bitCount/8), depth: 0}}
+
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if r.ipVersion == 6 && isIPv4Alias(current.ip, current.depth) {
+			continue
+		}
+
+		for bit := uint(0); bit < 2; bit++ {
+			record, err := r.readRecord(current.node, bit)
+			if err != nil {
+				return err
+			}
+
+			ip := make(net.IP, len(current.ip))
+			copy(ip, current.ip)
+			if bit == 1 {
+				ip[current.depth/8] |= 1 << (7 - current.depth%8)
+			}
+			depth := current.depth + 1
+
+			switch {
+			case record < r.nodeCount:
+				if depth >= bitCount {
+					return errors.New("invalid MaxMind DB: search tree is deeper than the address size")
+				}
+				stack = append(stack, entry{node: record, ip: ip, depth: depth})
+			case record == r.nodeCount:
+				// no data for this network
+			default:
+				offset := record - r.nodeCount - 16
+				if err := fn(network(ip, depth, bitCount), offset); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Record decodes the record at offset.
+func (r *Reader) Record(offset uint) (interface{}, error) {
+	value, _, err := r.data.decode(offset)
+	return value, err
+}
+
+func (r *Reader) readRecord(node uint, bit uint) (uint, error) {
+	nodeSize := r.recordSize / 4
+	start := node * nodeSize
+	if start+nodeSize > uint(len(r.buf)) {
+		return 0, errors.New("invalid MaxMind DB: node outside of search tree")
+	}
+	b := r.buf[start : start+nodeSize]
+
+	switch r.recordSize {
+	case 24:
+		b = b[bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2]), nil
+	case 28:
+		if bit == 0 {
+			return uint(b[3]&0xf0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2]), nil
+		}
+		return uint(b[3]&0x0f)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6]), nil
+	default:
+		return uint(binary.BigEndian.Uint32(b[bit*4:])), nil
+	}
+}
+
+func isIPv4Alias(ip net.IP, depth uint) bool {
+	switch depth {
+	case 16:
+		return ip[0] == 0x20 && ip[1] == 0x02
+	case 96:
+		return bytes.Equal(ip[:12], net.IP{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff})
+	}
+	return false
+}
+
+func network(ip net.IP, depth uint, bitCount uint) *net.IPNet {
+	if bitCount == 128 && depth >= 96 && bytes.Equal(ip[:12], make([]byte, 12)) {
+		return &net.IPNet{IP: ip[12:], Mask: net.CIDRMask(int(depth-96), 32)}
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(int(depth), int(bitCount))}
+}
+
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+type decoder struct {
+	buf []byte
+}
+
+// decode returns the value at offset and the offset following it.
+func (d *decoder) decode(offset uint) (interface{}, uint, error) {
+	b, offset, err := d.read(offset, 1)
+	if err != nil {
+		return nil, 0, err
+	}
+	ctrl := b[0]
+	kind := uint(ctrl >> 5)
+
+	if kind == typePointer {
+		return d.decodePointer(ctrl, offset)
+	}
+
+	if kind == typeExtended {
+		if b, offset, err = d.read(offset, 1); err != nil {
+			return nil, 0, err
+		}
+		kind = 7 + uint(b[0])
+	}
+
+	size := uint(ctrl & 0x1f)
+	if size >= 29 {
+		n := size - 28
+		if b, offset, err = d.read(offset, n); err != nil {
+			return nil, 0, err
+		}
+		extra := uint(0)
+		for _, c := range b {
+			extra = extra<<8 | uint(c)
+		}
+		size = [...]uint{29, 285, 65821}[n-1] + extra
+	}
+
+	switch kind {
+	case typeMap:
+		m := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			var key, value interface{}
+			if key, offset, err = d.decode(offset); err != nil {
+				return nil, 0, err
+			}
+			keyString, ok := key.(string)
+			if !ok {
+				return nil, 0, fmt.Errorf("map key at offset %d is not a string", offset)
+			}
+			if value, offset, err = d.decode(offset); err != nil {
+				return nil, 0, err
+			}
+			m[keyString] = value
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]interface{}, 0, size)
+		for i := uint(0); i < size; i++ {
+			var value interface{}
+			if value, offset, err = d.decode(offset); err != nil {
+				return nil, 0, err
+			}
+			a = append(a, value)
+		}
+		return a, offset, nil
+	case typeBool:
+		return size != 0, offset, nil
+	}
+
+	if b, offset, err = d.read(offset, size); err != nil {
+		return nil, 0, err
+	}
+
+	switch kind {
+	case typeString:
+		return string(b), offset, nil
+	case typeBytes:
+		return append([]byte(nil), b...), offset, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("invalid double size %d", size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), offset, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("invalid float size %d", size)
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(b)), offset, nil
+	case typeUint16, typeUint32, typeUint64:
+		if size > 8 {
+			return nil, 0, fmt.Errorf("invalid unsigned integer size %d", size)
+		}
+		n := uint64(0)
+		for _, c := range b {
+			n = n<<8 | uint64(c)
+		}
+		return n, offset, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("invalid int32 size %d", size)
+		}
+		n := uint32(0)
+		for _, c := range b {
+			n = n<<8 | uint32(c)
+		}
+		return int32(n), offset, nil
+	case typeUint128:
+		return new(big.Int).SetBytes(b), offset, nil
+	}
+
+	return nil, 0, fmt.Errorf("unsupported data type %d at offset %d", kind, offset)
+}
+
+func (d *decoder) decodePointer(ctrl byte, offset uint) (interface{}, uint, error) {
+	sizeBits := uint(ctrl>>3) & 0x3
+	b, next, err := d.read(offset, sizeBits+1)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	pointer := uint(0)
+	if sizeBits < 3 {
+		pointer = uint(ctrl & 0x7)
+	}
+	for _, c := range b {
+		pointer = pointer<<8 | uint(c)
+	}
+	pointer += [...]uint{0, 2048, 526336, 0}[sizeBits]
+
+	// pointers to pointers are invalid and would allow loops
+	if target, _, err := d.read(pointer, 1); err != nil {
+		return nil, 0, err
+	} else if target[0]>>5 == typePointer {
+		return nil, 0, fmt.Errorf("pointer at offset %d points to another pointer", offset)
+	}
+
+	value, _, err := d.decode(pointer)
+	return value, next, err
+}
+
+func (d *decoder) read(offset uint, n uint) ([]byte, uint, error) {
+	if offset+n > uint(len(d.buf)) {
+		return nil, 0, fmt.Errorf("unexpected end of data at offset %d", offset)
+	}
+	return d.buf[offset : offset+n], offset + n, nil
+}
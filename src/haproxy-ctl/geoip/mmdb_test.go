@@ -0,0 +1,316 @@
+package geoip
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// testNetwork is a network of a synthetic database. Records are encoded in the data section,
+// unless pointTo is set, in which case a pointer to the record of network pointTo is encoded.
+type testNetwork struct {
+	cidr    string
+	record  map[string]interface{}
+	pointTo int
+}
+
+// buildMMDB writes a minimal MaxMind DB containing networks.
+func buildMMDB(t *testing.T, ipVersion int, recordSize int, networks []testNetwork) []byte {
+	t.Helper()
+
+	type trieNode struct {
+		children [2]*trieNode
+		leaf     bool
+		offset   uint
+	}
+	root := &trieNode{}
+
+	var data bytes.Buffer
+	offsets := make([]uint, len(networks))
+	for i, n := range networks {
+		offsets[i] = uint(data.Len())
+		if n.pointTo > 0 {
+			target := offsets[n.pointTo-1]
+			data.Write([]byte{typePointer<<5 | byte(target>>8), byte(target)})
+		} else {
+			encode(t, &data, n.record)
+		}
+
+		ip, ipNet, err := net.ParseCIDR(n.cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ones, _ := ipNet.Mask.Size()
+		if ipVersion == 6 {
+			if ip.To4() != nil && !strings.Contains(n.cidr, ":") {
+				ones += 96
+				ip = append(make(net.IP, 12), ip.To4()...)
+			} else {
+				ip = ip.To16()
+			}
+		} else {
+			ip = ip.To4()
+		}
+
+		node := root
+		for depth := 0; depth < ones; depth++ {
+			bit := (ip[depth/8] >> (7 - depth%8)) & 1
+			if node.children[bit] == nil {
+				node.children[bit] = &trieNode{}
+			}
+			node = node.children[bit]
+		}
+		node.leaf = true
+		node.offset = offsets[i]
+	}
+
+	var internal []*trieNode
+	index := map[*trieNode]uint{}
+	for queue := []*trieNode{root}; len(queue) > 0; queue = queue[1:] {
+		index[queue[0]] = uint(len(internal))
+		internal = append(internal, queue[0])
+		for _, child := range queue[0].children {
+			if child != nil && !child.leaf {
+				queue = append(queue, child)
+			}
+		}
+	}
+	nodeCount := uint(len(internal))
+
+	var tree bytes.Buffer
+	for _, node := range internal {
+		var records [2]uint
+		for bit, child := range node.children {
+			switch {
+			case child == nil:
+				records[bit] = nodeCount
+			case child.leaf:
+				records[bit] = nodeCount + 16 + child.offset
+			default:
+				records[bit] = index[child]
+			}
+		}
+		switch recordSize {
+		case 24:
+			tree.Write([]byte{byte(records[0] >> 16), byte(records[0] >> 8), byte(records[0]), byte(records[1] >> 16), byte(records[1] >> 8), byte(records[1])})
+		case 28:
+			tree.Write([]byte{byte(records[0] >> 16), byte(records[0] >> 8), byte(records[0]), byte(records[0]>>20)&0xf0 | byte(records[1]>>24)&0x0f, byte(records[1] >> 16), byte(records[1] >> 8), byte(records[1])})
+		default:
+			t.Fatalf("unsupported record size %d", recordSize)
+		}
+	}
+
+	var db bytes.Buffer
+	db.Write(tree.Bytes())
+	db.Write(make([]byte, 16))
+	db.Write(data.Bytes())
+	db.Write(metadataMarker)
+	encode(t, &db, map[string]interface{}{
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(recordSize),
+		"ip_version":                  uint16(ipVersion),
+		"database_type":               "Test-Country",
+		"languages":                   []interface{}{"en"},
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(1700000000),
+	})
+	return db.Bytes()
+}
+
+func encode(t *testing.T, buf *bytes.Buffer, value interface{}) {
+	t.Helper()
+
+	switch v := value.(type) {
+	case string:
+		writeControl(buf, typeString, uint(len(v)))
+		buf.WriteString(v)
+	case uint16:
+		writeControl(buf, typeUint16, 2)
+		buf.Write([]byte{byte(v >> 8), byte(v)})
+	case uint32:
+		writeControl(buf, typeUint32, 4)
+		buf.Write([]byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)})
+	case uint64:
+		writeControl(buf, typeUint64, 8)
+		for shift := 56; shift >= 0; shift -= 8 {
+			buf.WriteByte(byte(v >> shift))
+		}
+	case bool:
+		size := uint(0)
+		if v {
+			size = 1
+		}
+		writeControl(buf, typeBool, size)
+	case []interface{}:
+		writeControl(buf, typeArray, uint(len(v)))
+		for _, item := range v {
+			encode(t, buf, item)
+		}
+	case map[string]interface{}:
+		writeControl(buf, typeMap, uint(len(v)))
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			encode(t, buf, key)
+			encode(t, buf, v[key])
+		}
+	default:
+		t.Fatalf("unsupported value %#v", value)
+	}
+}
+
+func writeControl(buf *bytes.Buffer, kind byte, size uint) {
+	var sizeBytes []byte
+	switch {
+	case size < 29:
+	case size < 285:
+		sizeBytes = []byte{byte(size - 29)}
+		size = 29
+	default:
+		size -= 285
+		sizeBytes = []byte{byte(size >> 8), byte(size)}
+		size = 30
+	}
+
+	if kind > 7 {
+		buf.Write([]byte{byte(size), kind - 7})
+	} else {
+		buf.WriteByte(kind<<5 | byte(size))
+	}
+	buf.Write(sizeBytes)
+}
+
+func country(code string) map[string]interface{} {
+	return map[string]interface{}{"iso_code": code, "names": map[string]interface{}{"en": strings.Repeat("x", 300)}}
+}
+
+func writeMapLines(t *testing.T, db []byte, field Field) []string {
+	t.Helper()
+
+	r, err := NewReader(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	count, err := WriteMap(r, field, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if out.Len() == 0 {
+		lines = nil
+	}
+	if count != len(lines) {
+		t.Errorf("expected count %d to match %d lines", count, len(lines))
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+var ipv6Networks = []testNetwork{
+	{cidr: "1.2.3.0/24", record: map[string]interface{}{"country": country("DE"), "is_in_european_union": true}},
+	{cidr: "1.2.4.0/23", record: map[string]interface{}{"registered_country": country("US")}},
+	{cidr: "10.0.0.0/8", record: map[string]interface{}{"autonomous_system_number": uint32(64512), "autonomous_system_organization": "Example"}},
+	{cidr: "2001:db8::/32", record: map[string]interface{}{"country": country("FR"), "autonomous_system_number": uint32(64513)}},
+	{cidr: "::ffff:1.2.3.0/120", record: map[string]interface{}{"country": country("DE")}},
+	{cidr: "2002:102:300::/40", record: map[string]interface{}{"country": country("DE")}},
+}
+
+func TestWriteMapCountryFromIPv6Database(t *testing.T) {
+	lines := writeMapLines(t, buildMMDB(t, 6, 28, ipv6Networks), Country)
+
+	expected := []string{"1.2.3.0/24 DE", "1.2.4.0/23 US", "2001:db8::/32 FR"}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("expected %v, got %v", expected, lines)
+	}
+}
+
+func TestWriteMapASNFromIPv6Database(t *testing.T) {
+	lines := writeMapLines(t, buildMMDB(t, 6, 24, ipv6Networks), ASN)
+
+	expected := []string{"10.0.0.0/8 64512", "2001:db8::/32 64513"}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("expected %v, got %v", expected, lines)
+	}
+}
+
+func TestWriteMapFromIPv4DatabaseWithPointers(t *testing.T) {
+	db := buildMMDB(t, 4, 24, []testNetwork{
+		{cidr: "192.0.2.0/24", record: map[string]interface{}{"country": country("NL")}},
+		{cidr: "198.51.100.128/25", pointTo: 1},
+		{cidr: "203.0.113.7/32", record: map[string]interface{}{"country": map[string]interface{}{}}},
+	})
+
+	lines := writeMapLines(t, db, Country)
+
+	expected := []string{"192.0.2.0/24 NL", "198.51.100.128/25 NL"}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("expected %v, got %v", expected, lines)
+	}
+}
+
+func TestWriteMapRejectsUnknownFields(t *testing.T) {
+	r, err := NewReader(buildMMDB(t, 4, 24, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := WriteMap(r, Field("city"), &bytes.Buffer{}); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestNewReaderRejectsInvalidDatabases(t *testing.T) {
+	valid := buildMMDB(t, 4, 24, []testNetwork{{cidr: "192.0.2.0/24", record: map[string]interface{}{"country": country("NL")}}})
+	metadataStart := bytes.LastIndex(valid, metadataMarker)
+
+	for name, db := range map[string][]byte{
+		"empty":              {},
+		"no metadata marker": valid[:metadataStart],
+		"truncated metadata": valid[:metadataStart+len(metadataMarker)+3],
+		"tree exceeds file":  append(append([]byte{}, metadataMarker...), valid[metadataStart+len(metadataMarker):]...),
+	} {
+		if _, err := NewReader(db); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestGenerateMapFile(t *testing.T) {
+	dir := t.TempDir()
+	databasePath := filepath.Join(dir, "country.mmdb")
+	mapPath := filepath.Join(dir, "country.map")
+	if err := os.WriteFile(databasePath, buildMMDB(t, 6, 28, ipv6Networks), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := GenerateMapFile(databasePath, Country, mapPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("expected 3 networks, got %d", count)
+	}
+
+	content, err := os.ReadFile(mapPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "1.2.3.0/24 DE\n") {
+		t.Errorf("unexpected map file content %q", content)
+	}
+
+	if _, err := GenerateMapFile(filepath.Join(dir, "missing.mmdb"), Country, mapPath); err == nil {
+		t.Error("expected an error for a missing database")
+	}
+}
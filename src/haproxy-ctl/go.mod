@@ -0,0 +1,5 @@
+module github.com/cloudfoundry/haproxy-boshrelease/src/haproxy-ctl
+
+go 1.25.0
+
+require gopkg.in/yaml.v2 v2.4.0
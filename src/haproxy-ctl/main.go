@@ -0,0 +1,217 @@
+// haproxy-ctl changes the configuration of a running HAProxy through its runtime API,
+// without a BOSH deploy.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry/haproxy-boshrelease/src/haproxy-ctl/acl"
+	"github.com/cloudfoundry/haproxy-boshrelease/src/haproxy-ctl/api"
+	"github.com/cloudfoundry/haproxy-boshrelease/src/haproxy-ctl/drain"
+	"github.com/cloudfoundry/haproxy-boshrelease/src/haproxy-ctl/filediscovery"
+	"github.com/cloudfoundry/haproxy-boshrelease/src/haproxy-ctl/geoip"
+	"github.com/cloudfoundry/haproxy-boshrelease/src/haproxy-ctl/outlier"
+	"github.com/cloudfoundry/haproxy-boshrelease/src/haproxy-ctl/runtimeapi"
+)
+
+const usage = `Usage: haproxy-ctl [flags] <command> [arguments]
+
+Commands:
+  acl list                          list the names of the CIDR lists
+  acl show <list>                   show the entries of a CIDR list
+  acl add <list> <cidr>...          add entries to a CIDR list
+  acl del <list> <cidr>...          remove entries from a CIDR list
+  geoip generate <field> <db> <map> convert the MaxMind DB <db> into the HAProxy map file <map>,
+                                    <field> is either 'country' or 'asn'
+  serve                             serve the HTTP API, credentials are read from
+                                    HAPROXY_CTL_USERNAME and HAPROXY_CTL_PASSWORD
+  outlier-guard <backend>=<percent>...
+                                    restore servers ejected after observed errors once more than
+                                    <percent> of the servers of <backend> are ejected
+  drain <backend>/<server>          lower the weight of the server to 0 over -ramp-down, then move it to
+                                    DRAIN and into MAINT once its sessions finished
+  ready <backend>/<server>          return a drained server into rotation, ramped up over its slowstart
+  file-discovery                    keep the servers of the backends marked in haproxy.config of -config-dir
+                                    in sync with the endpoints in their JSON or YAML file, checked every -interval
+
+Flags:
+`
+
+func main() {
+	flags := flag.NewFlagSet("haproxy-ctl", flag.ExitOnError)
+	socketPath := flags.String("socket", runtimeapi.DefaultSocketPath, "path of the HAProxy stats socket")
+	configDir := flags.String("config-dir", acl.DefaultConfigDir, "directory of the haproxy job configuration")
+	listen := flags.String("listen", "127.0.0.1:9090", "address the HTTP API listens on (serve only)")
+	rampDown := flags.Duration("ramp-down", time.Minute, "time over which the weight is lowered (drain only)")
+	rampDownSteps := flags.Int("ramp-down-steps", 10, "number of weight reductions (drain only)")
+	drainTimeout := flags.Duration("drain-timeout", 5*time.Minute, "time to wait for the sessions to finish (drain only)")
+	interval := flags.Duration("interval", 5*time.Second, "time between checks of the endpoint files (file-discovery only)")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(os.Args[1:])
+
+	acls := acl.NewManager(runtimeapi.NewClient(*socketPath), *configDir)
+
+	var err error
+	switch args := flags.Args(); {
+	case len(args) >= 2 && args[0] == "acl":
+		err = runACL(acls, args[1], args[2:])
+	case len(args) == 5 && args[0] == "geoip" && args[1] == "generate":
+		err = generateGeoIPMap(geoip.Field(args[2]), args[3], args[4])
+	case len(args) == 1 && args[0] == "serve":
+		err = serve(acls, *listen)
+	case len(args) >= 2 && args[0] == "outlier-guard":
+		err = guardOutliers(runtimeapi.NewClient(*socketPath), args[1:])
+	case len(args) == 2 && args[0] == "drain":
+		drainer := &drain.Drainer{Runtime: runtimeapi.NewClient(*socketPath), Steps: *rampDownSteps}
+		err = drainServer(drainer, args[1], *rampDown, *drainTimeout)
+	case len(args) == 2 && args[0] == "ready":
+		err = readyServer(&drain.Drainer{Runtime: runtimeapi.NewClient(*socketPath)}, args[1])
+	case len(args) == 1 && args[0] == "file-discovery":
+		err = discoverFromFiles(&filediscovery.Reconciler{Runtime: runtimeapi.NewClient(*socketPath)}, filepath.Join(*configDir, "haproxy.config"), *interval)
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "haproxy-ctl: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runACL(acls *acl.Manager, command string, args []string) error {
+	switch {
+	case command == "list" && len(args) == 0:
+		lists, err := acls.Lists()
+		if err != nil {
+			return err
+		}
+		fmt.Println(strings.Join(lists, "\n"))
+	case command == "show" && len(args) == 1:
+		entries, err := acls.Entries(args[0])
+		if err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			fmt.Println(strings.Join(entries, "\n"))
+		}
+	case command == "add" && len(args) >= 2:
+		return acls.Add(args[0], args[1:])
+	case command == "del" && len(args) >= 2:
+		return acls.Delete(args[0], args[1:])
+	default:
+		return fmt.Errorf("invalid arguments for 'acl %s', see 'haproxy-ctl -h'", command)
+	}
+	return nil
+}
+
+func generateGeoIPMap(field geoip.Field, databasePath string, mapPath string) error {
+	count, err := geoip.GenerateMapFile(databasePath, field, mapPath)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %d networks from %s to %s\n", count, databasePath, mapPath)
+	return nil
+}
+
+func serve(acls *acl.Manager, listen string) error {
+	credentials := api.Credentials{
+		Username: os.Getenv("HAPROXY_CTL_USERNAME"),
+		Password: os.Getenv("HAPROXY_CTL_PASSWORD"),
+	}
+	if credentials.Username == "" || credentials.Password == "" {
+		return errors.New("HAPROXY_CTL_USERNAME and HAPROXY_CTL_PASSWORD must be set")
+	}
+
+	server := &http.Server{
+		Addr:              listen,
+		Handler:           api.NewHandler(acls, credentials),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	log.Printf("Serving the haproxy-ctl API on %s", listen)
+	return server.ListenAndServe()
+}
+
+func guardOutliers(runtime runtimeapi.Runner, args []string) error {
+	limits, err := outlier.ParseLimits(args)
+	if err != nil {
+		return err
+	}
+
+	guard := &outlier.Guard{Runtime: runtime, MaxEjectedPercent: limits}
+	log.Printf("Guarding the ejected servers of %d backends", len(limits))
+	for range time.Tick(time.Second) {
+		restored, err := guard.Check()
+		// HAProxy is not reachable during restarts, so errors are only logged
+		if err != nil {
+			log.Printf("Checking ejected servers: %v", err)
+		}
+		for _, server := range restored {
+			log.Printf("Restored %s, too many servers of its backend are ejected", server)
+		}
+	}
+	return nil
+}
+
+func drainServer(drainer *drain.Drainer, server string, rampDown, timeout time.Duration) error {
+	server, err := drain.ParseServer(server)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Draining %s over %s", server, rampDown)
+	if err := drainer.Drain(server, rampDown, timeout); err != nil {
+		return err
+	}
+	log.Printf("Drained %s, it is in maintenance now", server)
+	return nil
+}
+
+func readyServer(drainer *drain.Drainer, server string) error {
+	server, err := drain.ParseServer(server)
+	if err != nil {
+		return err
+	}
+	return drainer.Ready(server)
+}
+
+func discoverFromFiles(reconciler *filediscovery.Reconciler, configPath string, interval time.Duration) error {
+	targets, err := filediscovery.ParseConfig(configPath)
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		return fmt.Errorf("no backends in %s are marked for file discovery", configPath)
+	}
+
+	log.Printf("Keeping the servers of %d backends in sync with their endpoint files", len(targets))
+	for ; ; time.Sleep(interval) {
+		for _, target := range targets {
+			// Servers are left as they are while the file is missing or invalid
+			endpoints, err := filediscovery.Load(target.File)
+			if err != nil {
+				log.Printf("Reading endpoints of %s: %v", target.Backend, err)
+				continue
+			}
+			changes, err := reconciler.Reconcile(target.Backend, target.Port, endpoints)
+			for _, change := range changes {
+				log.Printf("Drift from %s: %s", target.File, change)
+			}
+			if err != nil {
+				log.Printf("Updating servers of %s: %v", target.Backend, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Package outlier caps how many servers of a backend may be ejected at once after HAProxy observed
+// consecutive errors on live traffic (`observe ... on-error mark-down`), which HAProxy cannot limit itself.
+package outlier
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cloudfoundry/haproxy-boshrelease/src/haproxy-ctl/runtimeapi"
+)
+
+// Guard restores ejected servers of backends in which more servers are ejected than allowed.
+type Guard struct {
+	Runtime runtimeapi.Runner
+	// MaxEjectedPercent maps backend names to the percentage of their servers which may be ejected at once.
+	MaxEjectedPercent map[string]int
+}
+
+// ParseLimits parses arguments of the form <backend>=<percent>.
+func ParseLimits(args []string) (map[string]int, error) {
+	limits := map[string]int{}
+	for _, arg := range args {
+		backend, value, found := strings.Cut(arg, "=")
+		percent, err := strconv.Atoi(value)
+		if !found || backend == "" || err != nil || percent < 0 || percent > 100 {
+			return nil, fmt.Errorf("invalid limit %q, expected <backend>=<percent between 0 and 100>", arg)
+		}
+		limits[backend] = percent
+	}
+	return limits, nil
+}
+
+// ejected reports whether HAProxy took the server down after observing errors, which it reports as
+// check status HANA (health analyze). A down server with a passing check is rising after failed
+// active checks and must pass its rise checks before it is up again.
+func ejected(stat runtimeapi.Stat) bool {
+	return strings.HasPrefix(stat["status"], "DOWN") && stat["check_status"] == "HANA"
+}
+
+// Check restores as many ejected servers as exceed the limits and returns them as <backend>/<server>.
+func (g *Guard) Check() ([]string, error) {
+	stats, err := runtimeapi.ShowStat(g.Runtime)
+	if err != nil {
+		return nil, err
+	}
+
+	servers := map[string]int{}
+	ejectedServers := map[string][]string{}
+	for _, stat := range stats {
+		backend := stat["pxname"]
+		if _, ok := g.MaxEjectedPercent[backend]; !ok || !stat.IsServer() || stat["bck"] == "1" {
+			continue
+		}
+		servers[backend]++
+		if ejected(stat) {
+			ejectedServers[backend] = append(ejectedServers[backend], stat["svname"])
+		}
+	}
+
+	var restored []string
+	for backend, names := range ejectedServers {
+		allowed := servers[backend] * g.MaxEjectedPercent[backend] / 100
+		for _, name := range names[min(allowed, len(names)):] {
+			server := backend + "/" + name
+			if _, err := g.Runtime.Run("set server " + server + " health up"); err != nil {
+				return restored, err
+			}
+			restored = append(restored, server)
+		}
+	}
+	return restored, nil
+}
@@ -0,0 +1,106 @@
+package outlier
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+type fakeRunner struct {
+	commands []string
+	stat     string
+}
+
+func (f *fakeRunner) Run(command string) (string, error) {
+	f.commands = append(f.commands, command)
+	if command == "show stat" {
+		return f.stat, nil
+	}
+	return "", nil
+}
+
+const stat = "# pxname,svname,status,check_status,bck,\n" +
+	"api,FRONTEND,OPEN,,,\n" +
+	"api,node0,DOWN,HANA,0,\n" +
+	"api,node1,DOWN,HANA,0,\n" +
+	"api,node2,DOWN,L4CON,0,\n" +
+	"api,node3,UP,L7OK,0,\n" +
+	"api,BACKEND,UP,,0,\n" +
+	"other,node0,DOWN,HANA,0,\n"
+
+func TestCheckRestoresServersAboveTheLimit(t *testing.T) {
+	runner := &fakeRunner{stat: stat}
+	guard := &Guard{Runtime: runner, MaxEjectedPercent: map[string]int{"api": 25}}
+
+	restored, err := guard.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 1 of 4 servers may be ejected, node2 fails its active check and does not count
+	if !reflect.DeepEqual(restored, []string{"api/node1"}) {
+		t.Errorf("unexpected restored servers %v", restored)
+	}
+	if !reflect.DeepEqual(runner.commands, []string{"show stat", "set server api/node1 health up"}) {
+		t.Errorf("unexpected commands %v", runner.commands)
+	}
+}
+
+func TestCheckLeavesServersWithinTheLimit(t *testing.T) {
+	runner := &fakeRunner{stat: stat}
+	guard := &Guard{Runtime: runner, MaxEjectedPercent: map[string]int{"api": 50}}
+
+	restored, err := guard.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restored) != 0 {
+		t.Errorf("expected no restored servers, got %v", restored)
+	}
+}
+
+func TestCheckRestoresAllServersWithZeroPercent(t *testing.T) {
+	guard := &Guard{Runtime: &fakeRunner{stat: stat}, MaxEjectedPercent: map[string]int{"api": 0, "other": 0}}
+
+	restored, err := guard.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(restored)
+	if !reflect.DeepEqual(restored, []string{"api/node0", "api/node1", "other/node0"}) {
+		t.Errorf("unexpected restored servers %v", restored)
+	}
+}
+
+func TestCheckLeavesRisingServers(t *testing.T) {
+	runner := &fakeRunner{stat: "# pxname,svname,status,check_status,bck,\n" +
+		"api,node0,DOWN 1/2,L7OK,0,\n" +
+		"api,node1,UP,L7OK,0,\n"}
+	guard := &Guard{Runtime: runner, MaxEjectedPercent: map[string]int{"api": 0}}
+
+	restored, err := guard.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restored) != 0 {
+		t.Errorf("expected no restored servers, got %v", restored)
+	}
+	if !reflect.DeepEqual(runner.commands, []string{"show stat"}) {
+		t.Errorf("unexpected commands %v", runner.commands)
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	limits, err := ParseLimits([]string{"api=50", "http-routers-http1=10"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(limits, map[string]int{"api": 50, "http-routers-http1": 10}) {
+		t.Errorf("unexpected limits %v", limits)
+	}
+
+	for _, arg := range []string{"api", "=50", "api=", "api=101", "api=-1", "api=ten"} {
+		if _, err := ParseLimits([]string{arg}); err == nil {
+			t.Errorf("expected an error for %q", arg)
+		}
+	}
+}
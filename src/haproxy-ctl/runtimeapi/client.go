@@ -0,0 +1,58 @@
+// Package runtimeapi sends commands to the HAProxy runtime API exposed on the stats socket.
+package runtimeapi
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// DefaultSocketPath is the stats socket configured by the haproxy job.
+const DefaultSocketPath = "/var/vcap/sys/run/haproxy/stats.sock"
+
+// Runner runs a single runtime API command and returns its output.
+type Runner interface {
+	Run(command string) (string, error)
+}
+
+// Client is a Runner which connects to the stats socket for every command.
+type Client struct {
+	SocketPath string
+	Timeout    time.Duration
+}
+
+// NewClient returns a Client for the stats socket at socketPath.
+func NewClient(socketPath string) *Client {
+	return &Client{SocketPath: socketPath, Timeout: 10 * time.Second}
+}
+
+// Run sends command to HAProxy and returns the trimmed response.
+// HAProxy closes the connection after answering a single command in non-interactive mode.
+func (c *Client) Run(command string) (string, error) {
+	if strings.ContainsAny(command, "\n;") {
+		return "", fmt.Errorf("refusing to send command containing a newline or ';': %q", command)
+	}
+
+	conn, err := net.DialTimeout("unix", c.SocketPath, c.Timeout)
+	if err != nil {
+		return "", fmt.Errorf("connecting to %s: %w", c.SocketPath, err)
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(c.Timeout)); err != nil {
+		return "", err
+	}
+
+	if _, err := io.WriteString(conn, command+"\n"); err != nil {
+		return "", fmt.Errorf("sending %q: %w", command, err)
+	}
+
+	out, err := io.ReadAll(conn)
+	if err != nil {
+		return "", fmt.Errorf("reading response to %q: %w", command, err)
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
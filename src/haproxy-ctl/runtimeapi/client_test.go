@@ -0,0 +1,62 @@
+package runtimeapi
+
+import (
+	"bufio"
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+func startFakeSocket(t *testing.T, response string) (string, <-chan string) {
+	t.Helper()
+
+	socketPath := filepath.Join(t.TempDir(), "stats.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	commands := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		commands <- line
+		_, _ = conn.Write([]byte(response))
+	}()
+
+	return socketPath, commands
+}
+
+func TestRunSendsCommandAndReturnsResponse(t *testing.T) {
+	socketPath, commands := startFakeSocket(t, "Key not found.\n\n")
+
+	out, err := NewClient(socketPath).Run("del acl /tmp/list.txt 10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "Key not found." {
+		t.Errorf("unexpected output %q", out)
+	}
+	if cmd := <-commands; cmd != "del acl /tmp/list.txt 10.0.0.1\n" {
+		t.Errorf("unexpected command %q", cmd)
+	}
+}
+
+func TestRunRejectsCommandSeparators(t *testing.T) {
+	for _, command := range []string{"show info; shutdown frontend http-in", "show info\nshutdown frontend http-in"} {
+		if _, err := NewClient("/nonexistent").Run(command); err == nil {
+			t.Errorf("expected an error for %q", command)
+		}
+	}
+}
+
+func TestRunFailsWhenSocketIsMissing(t *testing.T) {
+	if _, err := NewClient(filepath.Join(t.TempDir(), "missing.sock")).Run("show info"); err == nil {
+		t.Error("expected an error")
+	}
+}
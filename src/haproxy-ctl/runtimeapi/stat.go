@@ -0,0 +1,48 @@
+package runtimeapi
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strings"
+)
+
+// Stat is one row of `show stat`, keyed by the column names such as "pxname", "svname" and "status".
+type Stat map[string]string
+
+// ShowStat runs `show stat` and parses its CSV output.
+func ShowStat(r Runner) ([]Stat, error) {
+	out, err := r.Run("show stat")
+	if err != nil {
+		return nil, err
+	}
+
+	header, body, _ := strings.Cut(out, "\n")
+	if !strings.HasPrefix(header, "# ") {
+		return nil, fmt.Errorf("unexpected response to 'show stat': %q", out)
+	}
+	columns := strings.Split(strings.TrimSuffix(strings.TrimPrefix(header, "# "), ","), ",")
+
+	reader := csv.NewReader(strings.NewReader(body))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("parsing 'show stat': %w", err)
+	}
+
+	stats := make([]Stat, 0, len(records))
+	for _, record := range records {
+		stat := Stat{}
+		for i, column := range columns {
+			if i < len(record) {
+				stat[column] = record[i]
+			}
+		}
+		stats = append(stats, stat)
+	}
+	return stats, nil
+}
+
+// IsServer reports whether the row describes a server rather than the totals of a frontend or backend.
+func (s Stat) IsServer() bool {
+	return s["svname"] != "FRONTEND" && s["svname"] != "BACKEND"
+}
@@ -0,0 +1,36 @@
+package runtimeapi
+
+import "testing"
+
+type fakeRunner map[string]string
+
+func (f fakeRunner) Run(command string) (string, error) {
+	return f[command], nil
+}
+
+func TestShowStatParsesColumns(t *testing.T) {
+	runner := fakeRunner{"show stat": "# pxname,svname,status,check_status,\n" +
+		"http-routers-http1,FRONTEND,OPEN,,\n" +
+		"http-routers-http1,node0,UP,L7OK,\n" +
+		"http-routers-http1,BACKEND,UP,,\n"}
+
+	stats, err := ShowStat(runner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 3 {
+		t.Fatalf("expected 3 rows, got %d", len(stats))
+	}
+	if stats[1]["svname"] != "node0" || stats[1]["status"] != "UP" || stats[1]["check_status"] != "L7OK" {
+		t.Errorf("unexpected row %v", stats[1])
+	}
+	if stats[0].IsServer() || !stats[1].IsServer() || stats[2].IsServer() {
+		t.Errorf("unexpected IsServer results for %v", stats)
+	}
+}
+
+func TestShowStatRejectsUnexpectedOutput(t *testing.T) {
+	if _, err := ShowStat(fakeRunner{"show stat": "Unknown command."}); err == nil {
+		t.Error("expected an error")
+	}
+}